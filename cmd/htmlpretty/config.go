@@ -0,0 +1,259 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/derat/htmlpretty"
+)
+
+// configNames contains the names of config files that are automatically loaded from
+// the directories containing input files and from their ancestors.
+var configNames = []string{".htmlpretty.json", ".htmlpretty.toml"}
+
+// configFile holds the contents of a config file.
+type configFile struct {
+	path string
+	data []byte // JSON-encoded htmlpretty.Options, plus an optional "root" bool
+}
+
+// readConfig reads the config file at p.
+// Files with a .toml extension are parsed as TOML, and all other files as JSON.
+func readConfig(p string) (*configFile, error) {
+	b, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	if strings.ToLower(filepath.Ext(p)) == ".toml" {
+		m, err := parseTOML(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", p, err)
+		}
+		if b, err = json.Marshal(m); err != nil {
+			return nil, fmt.Errorf("%v: %v", p, err)
+		}
+	}
+	cf := &configFile{path: p, data: b}
+	if err := cf.apply(&htmlpretty.Options{}); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// apply decodes cf's options into opts.
+// Fields that aren't present in the file are left unchanged, and unknown fields are errors.
+func (cf *configFile) apply(opts *htmlpretty.Options) error {
+	v := struct {
+		*htmlpretty.Options
+		Root bool `json:"root"` // read by isRoot
+	}{Options: opts}
+	dec := json.NewDecoder(bytes.NewReader(cf.data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("%v: %v", cf.path, err)
+	}
+	return nil
+}
+
+// isRoot returns true if cf has a true "root" property, indicating that config files in
+// ancestor directories should be ignored.
+func (cf *configFile) isRoot() bool {
+	var v struct {
+		Root bool `json:"root"`
+	}
+	json.Unmarshal(cf.data, &v) // already validated by readConfig
+	return v.Root
+}
+
+// configFinder finds config files that apply to directories.
+// It is safe for concurrent use.
+type configFinder struct {
+	mu    sync.Mutex
+	cache map[string][]*configFile // keyed by absolute dir
+}
+
+// find returns the config files that apply to files in dir, from outermost to innermost.
+func (cf *configFinder) find(dir string) ([]*configFile, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.findLocked(dir)
+}
+
+func (cf *configFinder) findLocked(dir string) ([]*configFile, error) {
+	if files, ok := cf.cache[dir]; ok {
+		return files, nil
+	}
+
+	var own []*configFile
+	root := false
+	for _, name := range configNames {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			continue
+		}
+		f, err := readConfig(p)
+		if err != nil {
+			return nil, err
+		}
+		own = append(own, f)
+		root = root || f.isRoot()
+	}
+
+	var files []*configFile
+	if parent := filepath.Dir(dir); parent != dir && !root {
+		var err error
+		if files, err = cf.findLocked(parent); err != nil {
+			return nil, err
+		}
+	}
+	files = append(append([]*configFile(nil), files...), own...)
+
+	if cf.cache == nil {
+		cf.cache = make(map[string][]*configFile)
+	}
+	cf.cache[dir] = files
+	return files, nil
+}
+
+// optionFlags maps from the names of command-line flags to the corresponding
+// JSON field names in htmlpretty.Options.
+var optionFlags = map[string]string{
+	"indent":           "indent",
+	"wrap":             "wrap",
+	"tab-width":        "tabWidth",
+	"strip-comments":   "stripComments",
+	"wrap-comments":    "wrapComments",
+	"safe-whitespace":  "safeWhitespace",
+	"css-display":      "cssDisplay",
+	"reindent-scripts": "reindentScripts",
+	"format-css":       "formatCSS",
+	"format-json":      "formatJSON",
+	"close-tags":       "closeTags",
+}
+
+// flagOverrides returns a JSON-encoded htmlpretty.Options containing the values of
+// flags in optionFlags that were explicitly passed on the command line.
+func flagOverrides() ([]byte, error) {
+	vals := make(map[string]interface{})
+	flag.Visit(func(f *flag.Flag) {
+		if key, ok := optionFlags[f.Name]; ok {
+			vals[key] = f.Value.(flag.Getter).Get()
+		}
+	})
+	return json.Marshal(vals)
+}
+
+// tagsFlag implements flag.Value for changes to htmlpretty.TagClass sets.
+// Values are of the form "class=tag,+tag,-tag", where tags without a prefix or
+// with a '+' prefix are added to the class and tags with a '-' prefix are removed.
+type tagsFlag map[htmlpretty.TagClass]htmlpretty.TagChanges
+
+func (f tagsFlag) String() string {
+	var parts []string
+	for class, changes := range f {
+		var tags []string
+		for _, t := range changes.Add {
+			tags = append(tags, "+"+t)
+		}
+		for _, t := range changes.Remove {
+			tags = append(tags, "-"+t)
+		}
+		parts = append(parts, string(class)+"="+strings.Join(tags, ","))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func (f tagsFlag) Set(v string) error {
+	parts := strings.SplitN(v, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("want class=tag,...")
+	}
+	class := htmlpretty.TagClass(parts[0])
+	changes := f[class]
+	for _, t := range strings.Split(parts[1], ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		switch t[0] {
+		case '-':
+			changes.Remove = append(changes.Remove, t[1:])
+		case '+':
+			changes.Add = append(changes.Add, t[1:])
+		default:
+			changes.Add = append(changes.Add, t)
+		}
+	}
+	f[class] = changes
+	return nil
+}
+
+// apply merges f's changes into opts.Tags, overriding any conflicting changes.
+func (f tagsFlag) apply(opts *htmlpretty.Options) {
+	if len(f) == 0 {
+		return
+	}
+	if opts.Tags == nil {
+		opts.Tags = make(map[htmlpretty.TagClass]htmlpretty.TagChanges)
+	}
+	for class, fc := range f {
+		changes := opts.Tags[class]
+		changes.Add = append(removeStrings(changes.Add, fc.Remove), fc.Add...)
+		changes.Remove = append(removeStrings(changes.Remove, fc.Add), fc.Remove...)
+		opts.Tags[class] = changes
+	}
+}
+
+// removeStrings returns a copy of vals without any of the strings in rm.
+func removeStrings(vals, rm []string) []string {
+	var res []string
+	for _, v := range vals {
+		found := false
+		for _, r := range rm {
+			if strings.EqualFold(v, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// listFlag implements flag.Value for a flag that can be passed multiple times.
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			items = append(items, v)
+		}
+	}
+	return items
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/derat/htmlpretty"
+)
+
+// writeFiles writes files (keyed by slash-separated paths relative to dir) within dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for p, data := range files {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htmlpretty_config_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		"good.json":     `{"indent": "\t", "wrap": 80, "root": true}`,
+		"good.toml":     "indent = \"\\t\"\nwrap = 80\n[tags.inline]\nadd = ['my-icon']\n",
+		"unknown.json":  `{"indnet": "\t"}`,
+		"unknown.toml":  "[tags.inline]\nadd = ['a']\nextra = 1\n",
+		"badvalue.json": `{"wrap": "wide"}`,
+		"octal.toml":    "wrap = 010\n",
+	})
+	for name, want := range map[string]*htmlpretty.Options{
+		"good.json": {Indent: "\t", Wrap: 80},
+		"good.toml": {Indent: "\t", Wrap: 80, Tags: map[htmlpretty.TagClass]htmlpretty.TagChanges{
+			htmlpretty.InlineClass: {Add: []string{"my-icon"}},
+		}},
+		"unknown.json":  nil,
+		"unknown.toml":  nil,
+		"badvalue.json": nil,
+		"octal.toml":    nil,
+	} {
+		cf, err := readConfig(filepath.Join(dir, name))
+		if want == nil {
+			if err == nil {
+				t.Errorf("readConfig(%q) unexpectedly succeeded", name)
+			}
+			continue
+		} else if err != nil {
+			t.Errorf("readConfig(%q) failed: %v", name, err)
+			continue
+		}
+		var got htmlpretty.Options
+		if err := cf.apply(&got); err != nil {
+			t.Errorf("apply for %q failed: %v", name, err)
+		} else if !reflect.DeepEqual(got, *want) {
+			t.Errorf("readConfig(%q) gave %+v; want %+v", name, got, *want)
+		}
+	}
+}
+
+func TestProcessor_Options(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htmlpretty_config_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		".editorconfig":                 "root = true\n[*]\nindent_style = tab\nmax_line_length = 90\n",
+		".htmlpretty.json":              `{"wrap": 100, "stripComments": true, "tabWidth": 4}`,
+		"sub/.htmlpretty.toml":          "wrap = 110\nwrapComments = true\n",
+		"sub/isolated/.htmlpretty.json": `{"root": true, "safeWhitespace": true}`,
+		"explicit.json":                 `{"wrapComments": false, "formatCSS": true}`,
+	})
+	explicit, err := readConfig(filepath.Join(dir, "explicit.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		file      string
+		config    *configFile
+		overrides string
+		want      htmlpretty.Options
+	}{
+		// .editorconfig is overridden by .htmlpretty.json.
+		{"a.html", nil, "{}", htmlpretty.Options{Indent: "\t", Wrap: 100, TabWidth: 4, StripComments: true}},
+		// Nested config files override outer ones.
+		{"sub/a.html", nil, "{}",
+			htmlpretty.Options{Indent: "\t", Wrap: 110, TabWidth: 4, StripComments: true, WrapComments: true}},
+		// "root" stops the search for config files, but not for .editorconfig.
+		{"sub/isolated/a.html", nil, "{}",
+			htmlpretty.Options{Indent: "\t", Wrap: 90, SafeWhitespace: true}},
+		// -config overrides found config files.
+		{"sub/a.html", explicit, "{}", htmlpretty.Options{Indent: "\t", Wrap: 110, TabWidth: 4,
+			StripComments: true, FormatCSS: true}},
+		// Flags override everything.
+		{"sub/a.html", explicit, `{"wrap": 60, "wrapComments": true, "indent": " "}`,
+			htmlpretty.Options{Indent: " ", Wrap: 60, TabWidth: 4, StripComments: true,
+				WrapComments: true, FormatCSS: true}},
+	} {
+		p := processor{
+			opts:         htmlpretty.Options{Indent: "  ", Wrap: 120},
+			config:       tc.config,
+			overrides:    []byte(tc.overrides),
+			editorConfig: true,
+		}
+		got, _, err := p.options(filepath.Join(dir, filepath.FromSlash(tc.file)))
+		if err != nil {
+			t.Errorf("options(%q) failed: %v", tc.file, err)
+		} else if !reflect.DeepEqual(*got, tc.want) {
+			t.Errorf("options(%q) = %+v; want %+v", tc.file, *got, tc.want)
+		}
+	}
+}
+
+func TestProcessor_ProcessFileConfigError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htmlpretty_config_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		".htmlpretty.json": `{"bogus": true}`,
+		"a.html":           "<p>a</p>",
+	})
+	name := filepath.Join(dir, "a.html")
+	var p processor
+	if _, err := p.processFile(name, nil, ioutil.Discard); err == nil {
+		t.Errorf("processFile(%q) unexpectedly succeeded", name)
+	} else if !strings.HasPrefix(err.Error(), name+": ") {
+		t.Errorf("processFile(%q) returned %q; want error prefixed by filename", name, err)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines printed around each change.
+const diffContext = 3
+
+// maxDiffEdits is the maximum number of edits that diffLines will search for before
+// giving up and reporting the differing regions as wholly replaced.
+const maxDiffEdits = 2000
+
+// diffOp describes how a line changed between two files.
+type diffOp byte
+
+const (
+	diffKeep   diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+// diffLine is a single line in a diff.
+type diffLine struct {
+	op   diffOp
+	text string // includes trailing newline (if any)
+}
+
+// unifiedDiff returns a unified diff transforming a (named aName) into b (named bName).
+// An empty slice is returned if a and b are identical.
+func unifiedDiff(aName, bName string, a, b []byte) []byte {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "diff %s %s\n--- %s\n+++ %s\n", aName, bName, aName, bName)
+
+	// Compute the 1-based line numbers in a and b at the start of each line.
+	aNums := make([]int, len(lines)+1)
+	bNums := make([]int, len(lines)+1)
+	aNums[0], bNums[0] = 1, 1
+	for i, l := range lines {
+		aNums[i+1], bNums[i+1] = aNums[i], bNums[i]
+		if l.op != diffInsert {
+			aNums[i+1]++
+		}
+		if l.op != diffDelete {
+			bNums[i+1]++
+		}
+	}
+
+	for i := 0; ; {
+		for i < len(lines) && lines[i].op == diffKeep {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		// Extend the hunk to include changes separated by short runs of unchanged lines.
+		end := i
+		for end < len(lines) {
+			if lines[end].op != diffKeep {
+				end++
+				continue
+			}
+			run := 0
+			for end+run < len(lines) && lines[end+run].op == diffKeep {
+				run++
+			}
+			if end+run == len(lines) || run > 2*diffContext {
+				break
+			}
+			end += run
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		if end += diffContext; end > len(lines) {
+			end = len(lines)
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aNums[start], aNums[end]-aNums[start]),
+			hunkRange(bNums[start], bNums[end]-bNums[start]))
+		for _, l := range lines[start:end] {
+			out.WriteByte(byte(l.op))
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// hunkRange formats a hunk's starting line number and line count.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprint(start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+// splitLines splits b into lines, each including its trailing newline.
+func splitLines(b []byte) []string {
+	var lines []string
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			i = len(b) - 1
+		}
+		lines = append(lines, string(b[:i+1]))
+		b = b[i+1:]
+	}
+	return lines
+}
+
+// diffLines returns a minimal sequence of edits transforming a into b using
+// Myers's O(ND) algorithm.
+func diffLines(a, b []string) []diffLine {
+	// Trim common prefixes and suffixes to keep the search small.
+	var pre, suf []diffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		pre = append(pre, diffLine{diffKeep, a[0]})
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		suf = append([]diffLine{{diffKeep, a[len(a)-1]}}, suf...)
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxDiffEdits {
+		max = maxDiffEdits
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	var mid []diffLine
+	if !found {
+		// Too many differences; just replace everything.
+		for _, l := range a {
+			mid = append(mid, diffLine{diffDelete, l})
+		}
+		for _, l := range b {
+			mid = append(mid, diffLine{diffInsert, l})
+		}
+	} else {
+		// Walk backwards through the trace to recover the edits.
+		x, y := n, m
+		for d := len(trace) - 1; d > 0; d-- {
+			v := trace[d]
+			k := x - y
+			var prevK int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				prevK = k + 1
+			} else {
+				prevK = k - 1
+			}
+			prevX := v[off+prevK]
+			prevY := prevX - prevK
+			for x > prevX && y > prevY {
+				mid = append(mid, diffLine{diffKeep, a[x-1]})
+				x--
+				y--
+			}
+			if x == prevX {
+				mid = append(mid, diffLine{diffInsert, b[y-1]})
+			} else {
+				mid = append(mid, diffLine{diffDelete, a[x-1]})
+			}
+			x, y = prevX, prevY
+		}
+		for x > 0 && y > 0 {
+			mid = append(mid, diffLine{diffKeep, a[x-1]})
+			x--
+			y--
+		}
+		for i, j := 0, len(mid)-1; i < j; i, j = i+1, j-1 {
+			mid[i], mid[j] = mid[j], mid[i]
+		}
+	}
+
+	return append(append(pre, mid...), suf...)
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numberedLines returns newline-terminated lines containing the numbers from 1 to n,
+// with the lines in repl replaced by the corresponding values.
+func numberedLines(n int, repl map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if s, ok := repl[i]; ok {
+			b.WriteString(s + "\n")
+		} else {
+			fmt.Fprintf(&b, "%d\n", i)
+		}
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	const header = "diff a b\n--- a\n+++ b\n"
+	for _, tc := range []struct {
+		desc, a, b, exp string
+	}{
+		{"empty", "", "", ""},
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"add to empty", "", "a\nb\n", header + "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"delete all", "a\nb\n", "", header + "@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"insert", "1\n2\n3\n4\n5\n", "1\n2\nx\n3\n4\n5\n",
+			header + "@@ -1,5 +1,6 @@\n 1\n 2\n+x\n 3\n 4\n 5\n"},
+		{"delete", "1\n2\n3\n4\n5\n6\n7\n8\n", "1\n2\n3\n4\n6\n7\n8\n",
+			header + "@@ -2,7 +2,6 @@\n 2\n 3\n 4\n-5\n 6\n 7\n 8\n"},
+		{"add trailing newline", "a\nb", "a\nb\n",
+			header + "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"remove trailing newline", "a\n", "a",
+			header + "@@ -1 +1 @@\n-a\n+a\n\\ No newline at end of file\n"},
+		{"merged hunks", numberedLines(20, nil), numberedLines(20, map[int]string{2: "b", 9: "i"}),
+			header + "@@ -1,12 +1,12 @@\n 1\n-2\n+b\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+i\n 10\n 11\n 12\n"},
+		{"separate hunks", numberedLines(20, nil), numberedLines(20, map[int]string{2: "b", 10: "j"}),
+			header + "@@ -1,5 +1,5 @@\n 1\n-2\n+b\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+j\n 11\n 12\n 13\n"},
+	} {
+		if got := string(unifiedDiff("a", "b", []byte(tc.a), []byte(tc.b))); got != tc.exp {
+			t.Errorf("%s: unifiedDiff(%q, %q) = %q; want %q", tc.desc, tc.a, tc.b, got, tc.exp)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// The edits should be minimal, and applying them should produce b.
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	var edits int
+	var got []string
+	for _, l := range diffLines(a, b) {
+		switch l.op {
+		case diffKeep:
+			got = append(got, l.text)
+		case diffInsert:
+			got = append(got, l.text)
+			edits++
+		case diffDelete:
+			edits++
+		}
+	}
+	if strings.Join(got, " ") != strings.Join(b, " ") {
+		t.Errorf("diffLines(%q, %q) produced %q", a, b, got)
+	}
+	if edits != 5 {
+		t.Errorf("diffLines(%q, %q) used %d edits; want 5", a, b, edits)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// editorConfigFile holds a parsed .editorconfig file.
+// See https://editorconfig.org/ and https://spec.editorconfig.org/.
+type editorConfigFile struct {
+	dir      string
+	root     bool
+	sections []editorConfigSection
+}
+
+// editorConfigSection is a single section in an .editorconfig file.
+type editorConfigSection struct {
+	re    *regexp.Regexp // matches slash-separated paths relative to the file's dir
+	props map[string]string
+}
+
+// readEditorConfig parses the .editorconfig file at p.
+func readEditorConfig(p string) (*editorConfigFile, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ec := &editorConfigFile{dir: filepath.Dir(p)}
+	var sec *editorConfigSection
+	sc := bufio.NewScanner(f)
+	for ln := 1; sc.Scan(); ln++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			re, err := editorConfigGlob(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%v:%d: %v", p, ln, err)
+			}
+			ec.sections = append(ec.sections, editorConfigSection{re: re, props: make(map[string]string)})
+			sec = &ec.sections[len(ec.sections)-1]
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%v:%d: bad line %q", p, ln, line)
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		val := strings.TrimSpace(parts[1])
+		if sec == nil {
+			// Only "root" is allowed in the preamble.
+			if key == "root" {
+				ec.root = strings.ToLower(val) == "true"
+			}
+			continue
+		}
+		sec.props[key] = val
+	}
+	return ec, sc.Err()
+}
+
+// editorConfigGlob converts an EditorConfig section glob into a regular expression
+// matching slash-separated paths relative to the .editorconfig file's directory.
+func editorConfigGlob(glob string) (*regexp.Regexp, error) {
+	// Globs without slashes match files in any directory.
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				break
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case c == '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 {
+				re.WriteString(`\{`)
+				break
+			}
+			// Handle numeric ranges like {1..3}.
+			if m := numRangeRegexp.FindStringSubmatch(glob[i : i+end+1]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				var nums []string
+				for n := lo; n <= hi && len(nums) < 1000; n++ {
+					nums = append(nums, strconv.Itoa(n))
+				}
+				re.WriteString("(?:" + strings.Join(nums, "|") + ")")
+				i += end
+				break
+			}
+			if !strings.Contains(glob[i:i+end], ",") {
+				re.WriteString(regexp.QuoteMeta(glob[i : i+end+1]))
+				i += end
+				break
+			}
+			re.WriteString("(?:")
+			braces++
+		case c == ',' && braces > 0:
+			re.WriteString("|")
+		case c == '}' && braces > 0:
+			re.WriteString(")")
+			braces--
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+	return regexp.Compile(re.String())
+}
+
+var numRangeRegexp = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}$`)
+
+// editorConfigFinder finds EditorConfig properties for files.
+// It is safe for concurrent use.
+type editorConfigFinder struct {
+	mu    sync.Mutex
+	cache map[string][]*editorConfigFile // keyed by absolute dir
+}
+
+// props returns the EditorConfig properties that apply to the file at p.
+func (ef *editorConfigFinder) props(p string) (map[string]string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+	ef.mu.Lock()
+	files, err := ef.findLocked(filepath.Dir(p))
+	ef.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Files and sections are ordered from lowest to highest precedence.
+	props := make(map[string]string)
+	for _, ec := range files {
+		rel, err := filepath.Rel(ec.dir, p)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, sec := range ec.sections {
+			if sec.re.MatchString(rel) {
+				for k, v := range sec.props {
+					props[k] = v
+				}
+			}
+		}
+	}
+	return props, nil
+}
+
+// findLocked returns the .editorconfig files that apply to dir, from outermost to innermost.
+// ef.mu must be held.
+func (ef *editorConfigFinder) findLocked(dir string) ([]*editorConfigFile, error) {
+	if files, ok := ef.cache[dir]; ok {
+		return files, nil
+	}
+
+	var own *editorConfigFile
+	p := filepath.Join(dir, ".editorconfig")
+	if _, err := os.Stat(p); err == nil {
+		if own, err = readEditorConfig(p); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []*editorConfigFile
+	if parent := filepath.Dir(dir); parent != dir && (own == nil || !own.root) {
+		var err error
+		if files, err = ef.findLocked(parent); err != nil {
+			return nil, err
+		}
+	}
+	if own != nil {
+		files = append(append([]*editorConfigFile(nil), files...), own)
+	}
+
+	if ef.cache == nil {
+		ef.cache = make(map[string][]*editorConfigFile)
+	}
+	ef.cache[dir] = files
+	return files, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEditorConfigGlob(t *testing.T) {
+	for _, tc := range []struct {
+		glob string
+		path string
+		want bool
+	}{
+		{"*", "a.html", true},
+		{"*", "sub/a.html", true},
+		{"*.html", "sub/dir/a.html", true},
+		{"*.html", "a.htm", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"/a.html", "a.html", true},
+		{"/a.html", "sub/a.html", false},
+		{"sub/*.html", "sub/a.html", true},
+		{"sub/*.html", "sub/dir/a.html", false},
+		{"sub/*.html", "x/sub/a.html", false},
+		{"sub/**.html", "sub/dir/a.html", true},
+		{"**/b.html", "b.html", true},
+		{"**/b.html", "a/b/b.html", true},
+		{"a/**/b.html", "a/b.html", true},
+		{"a/**/b.html", "a/x/y/b.html", true},
+		{"*.{html,htm}", "a.htm", true},
+		{"*.{html,htm}", "a.html", true},
+		{"*.{html,htm}", "a.xhtml", false},
+		{"{a,b/c}.html", "b/c.html", true},
+		{"{single}.html", "{single}.html", true},
+		{"{single}.html", "single.html", false},
+		{"file{1..3}.html", "file2.html", true},
+		{"file{1..3}.html", "file4.html", false},
+		{"file{-1..1}.html", "file-1.html", true},
+		{"[ab].html", "b.html", true},
+		{"[ab].html", "c.html", false},
+		{"[!ab].html", "c.html", true},
+		{"[!ab].html", "a.html", false},
+		{"[a-c].html", "b.html", true},
+		{"[unterminated.html", "[unterminated.html", true},
+		{`\*.html`, "*.html", true},
+		{`\*.html`, "a.html", false},
+		{"a.b", "axb", false},
+	} {
+		re, err := editorConfigGlob(tc.glob)
+		if err != nil {
+			t.Errorf("editorConfigGlob(%q) failed: %v", tc.glob, err)
+			continue
+		}
+		if got := re.MatchString(tc.path); got != tc.want {
+			t.Errorf("editorConfigGlob(%q) (%q) matched %q = %v; want %v",
+				tc.glob, re.String(), tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestEditorConfigFinder_Props(t *testing.T) {
+	dir, err := ioutil.TempDir("", "htmlpretty_editorconfig_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{
+		".editorconfig": "root = true\n" +
+			"[*]\nindent_style = space\nindent_size = 2\nmax_line_length = 80\n" +
+			"[*.html]\nindent_size = 4\n" +
+			"; later sections take precedence\n[special.html]\nindent_size = 8\n",
+		"sub/.editorconfig":      "[*.html]\nmax_line_length = 100\n",
+		"sub/root/.editorconfig": "root = true\n[*]\nindent_style = tab\n",
+	})
+
+	var ef editorConfigFinder
+	for _, tc := range []struct {
+		file string
+		want map[string]string
+	}{
+		{"a.txt", map[string]string{"indent_style": "space", "indent_size": "2", "max_line_length": "80"}},
+		{"a.html", map[string]string{"indent_style": "space", "indent_size": "4", "max_line_length": "80"}},
+		{"special.html", map[string]string{"indent_style": "space", "indent_size": "8", "max_line_length": "80"}},
+		// Files in subdirectories take precedence.
+		{"sub/a.html", map[string]string{"indent_style": "space", "indent_size": "4", "max_line_length": "100"}},
+		{"sub/dir/special.html",
+			map[string]string{"indent_style": "space", "indent_size": "8", "max_line_length": "100"}},
+		// "root = true" stops the search.
+		{"sub/root/a.html", map[string]string{"indent_style": "tab"}},
+	} {
+		got, err := ef.props(filepath.Join(dir, filepath.FromSlash(tc.file)))
+		if err != nil {
+			t.Errorf("props(%q) failed: %v", tc.file, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("props(%q) = %v; want %v", tc.file, got, tc.want)
+		}
+	}
+}
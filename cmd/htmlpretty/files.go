@@ -0,0 +1,259 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/derat/htmlpretty"
+	"golang.org/x/net/html"
+)
+
+// processor formats files as requested by command-line flags.
+type processor struct {
+	opts      htmlpretty.Options // defaults from flags
+	configs   configFinder       // finds config files for input files
+	config    *configFile        // config file passed via -config, or nil
+	overrides []byte             // JSON-encoded options from explicitly-passed flags
+	tags      tagsFlag           // tag class changes from flags
+
+	editorConfig  bool               // use .editorconfig files
+	editorConfigs editorConfigFinder // finds EditorConfig properties for input files
+
+	fragment bool   // parse input as fragments rather than documents
+	context  string // context element for fragments
+	minify   bool   // minify instead of pretty-printing
+
+	list   bool // list files whose formatting differs
+	write  bool // rewrite files in place
+	diff   bool // print diffs
+	check  bool // report files whose formatting differs
+	verify bool // check that formatting doesn't change the document's meaning
+}
+
+// options returns the options to use for formatting the file at name, along with the
+// line ending to use. If name is empty, options are computed for the current directory.
+// Options from flags take precedence over ones from -config, which take precedence over
+// ones from config files found in the file's directory and its ancestors, which take
+// precedence over EditorConfig properties.
+func (p *processor) options(name string) (opts *htmlpretty.Options, eol string, err error) {
+	o := p.opts
+	eol = "\n"
+	dir := "."
+	if name != "" {
+		dir = filepath.Dir(name)
+		if p.editorConfig {
+			props, err := p.editorConfigs.props(name)
+			if err != nil {
+				return nil, "", err
+			}
+			o.ApplyEditorConfig(props)
+			switch strings.ToLower(props["end_of_line"]) {
+			case "crlf":
+				eol = "\r\n"
+			case "cr":
+				eol = "\r"
+			}
+		}
+	}
+
+	configs, err := p.configs.find(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	if p.config != nil {
+		configs = append(configs, p.config)
+	}
+	for _, cf := range configs {
+		if err := cf.apply(&o); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := json.Unmarshal(p.overrides, &o); err != nil {
+		return nil, "", err
+	}
+	p.tags.apply(&o)
+	return &o, eol, nil
+}
+
+// format parses and pretty-prints src using opts.
+func (p *processor) format(src []byte, opts *htmlpretty.Options) ([]byte, error) {
+	var b bytes.Buffer
+	var err error
+	switch {
+	case p.minify && p.fragment:
+		err = htmlpretty.MinifyFragmentSource(&b, src, p.context, opts)
+	case p.minify:
+		err = htmlpretty.MinifyDocument(&b, src, opts)
+	case p.fragment:
+		err = htmlpretty.FormatFragment(&b, src, p.context, opts)
+	default:
+		err = htmlpretty.FormatDocument(&b, src, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed formatting HTML: %v", err)
+	}
+	if p.verify {
+		if err := p.verifyOutput(src, b.Bytes(), opts); err != nil {
+			return nil, fmt.Errorf("formatting changed meaning: %v", err)
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// verifyOutput reparses src and checks that res is equivalent to it.
+func (p *processor) verifyOutput(src, res []byte, opts *htmlpretty.Options) error {
+	if p.fragment {
+		nodes, err := htmlpretty.ParseFragment(bytes.NewReader(src), p.context)
+		if err != nil {
+			return err
+		}
+		return htmlpretty.VerifyFragment(nodes, res, p.context, opts)
+	}
+	root, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	return htmlpretty.Verify(root, res, opts)
+}
+
+// processFile formats the file at name and writes output to out as requested by p.
+// If in is non-nil, the input is read from it instead of from the file.
+// changed is true if the formatted output differs from the original input.
+func (p *processor) processFile(name string, in io.Reader, out io.Writer) (changed bool, err error) {
+	perm := os.FileMode(0644)
+	path := "" // use the current directory's config for stdin
+	if in == nil {
+		path = name
+		f, err := os.Open(name)
+		if err != nil {
+			return false, err
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			return false, err
+		}
+		perm = fi.Mode().Perm()
+		in = f
+	}
+
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return false, err
+	}
+	opts, eol, err := p.options(path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", name, err)
+	}
+	res, err := p.format(src, opts)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", name, err)
+	}
+	if eol != "\n" {
+		res = bytes.Replace(res, []byte("\n"), []byte(eol), -1)
+	}
+
+	changed = !bytes.Equal(src, res)
+	if changed {
+		if p.list {
+			fmt.Fprintln(out, name)
+		}
+		if p.check {
+			fmt.Fprintf(out, "%s: not formatted\n", name)
+		}
+		if p.write {
+			if err := writeFile(name, res, perm); err != nil {
+				return changed, err
+			}
+		}
+		if p.diff {
+			out.Write(unifiedDiff(name+".orig", name, src, res))
+		}
+	}
+	if !p.list && !p.write && !p.diff && !p.check {
+		_, err = out.Write(res)
+	}
+	return changed, err
+}
+
+// processFiles calls processFile for each of the supplied files using up to jobs
+// concurrent workers. Output and errors are written to out and errOut in the order in
+// which files were supplied. The number of files that failed and the number of files
+// whose formatting differed are returned.
+func (p *processor) processFiles(files []string, jobs int, out, errOut io.Writer) (failed, changed int) {
+	type result struct {
+		out     bytes.Buffer
+		changed bool
+		err     error
+		done    chan struct{}
+	}
+	results := make([]result, len(files))
+	for i := range results {
+		results[i].done = make(chan struct{})
+	}
+
+	ch := make(chan int)
+	go func() {
+		for i := range files {
+			ch <- i
+		}
+		close(ch)
+	}()
+	for j := 0; j < jobs; j++ {
+		go func() {
+			for i := range ch {
+				res := &results[i]
+				res.changed, res.err = p.processFile(files[i], nil, &res.out)
+				close(res.done)
+			}
+		}()
+	}
+
+	// Write results in order as they become available.
+	for i := range results {
+		res := &results[i]
+		<-res.done
+		out.Write(res.out.Bytes())
+		res.out.Reset()
+		if res.err != nil {
+			fmt.Fprintln(errOut, res.err)
+			failed++
+		}
+		if res.changed {
+			changed++
+		}
+	}
+	return failed, changed
+}
+
+// writeFile atomically replaces the file at name with data and sets its permissions to perm.
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // fails harmlessly after a successful rename
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single gitignore-style pattern.
+// See https://git-scm.com/docs/gitignore#_pattern_format.
+type ignoreRule struct {
+	base    string         // directory that the pattern is relative to
+	re      *regexp.Regexp // matches slash-separated paths relative to base
+	negate  bool           // pattern started with '!'
+	dirOnly bool           // pattern ended with '/'
+}
+
+// newIgnoreRule parses the gitignore-style pattern pat, which is relative to dir.
+// ok is false if pat is blank or a comment.
+func newIgnoreRule(pat, dir string) (rule ignoreRule, ok bool, err error) {
+	// Trailing spaces are ignored unless they're escaped.
+	for strings.HasSuffix(pat, " ") && !strings.HasSuffix(pat, `\ `) {
+		pat = pat[:len(pat)-1]
+	}
+	if pat == "" || pat[0] == '#' {
+		return rule, false, nil
+	}
+
+	rule.base = dir
+	if pat[0] == '!' {
+		rule.negate = true
+		pat = pat[1:]
+	} else if strings.HasPrefix(pat, `\!`) || strings.HasPrefix(pat, `\#`) {
+		pat = pat[1:]
+	}
+	if strings.HasSuffix(pat, "/") {
+		rule.dirOnly = true
+		pat = strings.TrimRight(pat, "/")
+	}
+	if pat == "" {
+		return rule, false, nil
+	}
+
+	// Patterns containing a slash anywhere but the end are relative to dir.
+	// Others can match at any depth.
+	anchored := strings.Contains(pat, "/")
+	pat = strings.TrimPrefix(pat, "/")
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pat); i++ {
+		c := pat[i]
+		switch {
+		case strings.HasPrefix(pat[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pat[i:], "/**") && i+3 == len(pat):
+			re.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(pat[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pat[i+1:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				break
+			}
+			class := pat[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pat):
+			i++
+			re.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(pat[i : i+1]))
+		}
+	}
+	re.WriteString("$")
+
+	if rule.re, err = regexp.Compile(re.String()); err != nil {
+		return rule, false, fmt.Errorf("bad pattern %q: %v", pat, err)
+	}
+	return rule, true, nil
+}
+
+// ignoreList holds gitignore-style rules.
+type ignoreList []ignoreRule
+
+// add parses pat as a pattern relative to dir and adds it to l.
+func (l *ignoreList) add(pat, dir string) error {
+	rule, ok, err := newIgnoreRule(pat, dir)
+	if ok {
+		*l = append(*l, rule)
+	}
+	return err
+}
+
+// addFile reads patterns from the file at p, which need not exist.
+func (l *ignoreList) addFile(p string) error {
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if err := l.add(strings.TrimSuffix(sc.Text(), "\r"), filepath.Dir(p)); err != nil {
+			return fmt.Errorf("%v: %v", p, err)
+		}
+	}
+	return sc.Err()
+}
+
+// ignored returns true if the file or directory at p is matched by l.
+// As in gitignore, later rules take precedence over earlier ones.
+func (l ignoreList) ignored(p string, isDir bool) bool {
+	ignored := false
+	for _, rule := range l {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, p)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rule.re.MatchString(filepath.ToSlash(rel)) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
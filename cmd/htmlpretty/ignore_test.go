@@ -0,0 +1,73 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreList(t *testing.T) {
+	for _, tc := range []struct {
+		pats  []string // patterns relative to base
+		base  string
+		path  string
+		isDir bool
+		want  bool
+	}{
+		// Patterns without slashes match at any depth.
+		{[]string{"*.html"}, "/r", "/r/a.html", false, true},
+		{[]string{"*.html"}, "/r", "/r/sub/a.html", false, true},
+		{[]string{"*.html"}, "/r", "/r/a.htm", false, false},
+		{[]string{"a?c"}, "/r", "/r/abc", false, true},
+		{[]string{"a?c"}, "/r", "/r/a/c", false, false},
+		{[]string{"[!a]x"}, "/r", "/r/bx", false, true},
+		{[]string{"[!a]x"}, "/r", "/r/ax", false, false},
+
+		// Patterns with leading or middle slashes are anchored to the base directory.
+		{[]string{"/a.html"}, "/r", "/r/a.html", false, true},
+		{[]string{"/a.html"}, "/r", "/r/sub/a.html", false, false},
+		{[]string{"sub/a.html"}, "/r", "/r/sub/a.html", false, true},
+		{[]string{"sub/a.html"}, "/r", "/r/x/sub/a.html", false, false},
+		{[]string{"*.html"}, "/r/sub", "/r/a.html", false, false},
+		{[]string{"*.html"}, "/r/sub", "/r/sub/x/a.html", false, true},
+
+		// "**" matches across directories.
+		{[]string{"**/foo"}, "/r", "/r/foo", false, true},
+		{[]string{"**/foo"}, "/r", "/r/a/b/foo", false, true},
+		{[]string{"a/**/b"}, "/r", "/r/a/b", false, true},
+		{[]string{"a/**/b"}, "/r", "/r/a/x/y/b", false, true},
+		{[]string{"a/**/b"}, "/r", "/r/x/a/b", false, false},
+		{[]string{"a/**"}, "/r", "/r/a/x/y", false, true},
+		{[]string{"a/**"}, "/r", "/r/a", true, false},
+
+		// Trailing slashes only match directories.
+		{[]string{"build/"}, "/r", "/r/build", true, true},
+		{[]string{"build/"}, "/r", "/r/build", false, false},
+		{[]string{"build/"}, "/r", "/r/sub/build", true, true},
+
+		// Later patterns take precedence, including negated ones.
+		{[]string{"*.html", "!keep.html"}, "/r", "/r/keep.html", false, false},
+		{[]string{"*.html", "!keep.html"}, "/r", "/r/drop.html", false, true},
+		{[]string{"!keep.html", "*.html"}, "/r", "/r/keep.html", false, true},
+
+		// Escapes, comments, and blank lines.
+		{[]string{`\!bang.html`}, "/r", "/r/!bang.html", false, true},
+		{[]string{`\#hash.html`}, "/r", "/r/#hash.html", false, true},
+		{[]string{"#a.html", "", "   "}, "/r", "/r/#a.html", false, false},
+		{[]string{`a.html\ `}, "/r", "/r/a.html ", false, true},
+		{[]string{"a.html  "}, "/r", "/r/a.html", false, true},
+	} {
+		var l ignoreList
+		for _, pat := range tc.pats {
+			if err := l.add(pat, filepath.FromSlash(tc.base)); err != nil {
+				t.Fatalf("add(%q) failed: %v", pat, err)
+			}
+		}
+		if got := l.ignored(filepath.FromSlash(tc.path), tc.isDir); got != tc.want {
+			t.Errorf("%q in %v ignored(%q, %v) = %v; want %v",
+				tc.pats, tc.base, tc.path, tc.isDir, got, tc.want)
+		}
+	}
+}
@@ -7,28 +7,131 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"strings"
+)
 
-	"golang.org/x/net/html"
-
-	"github.com/derat/htmlpretty"
+// Exit codes.
+const (
+	exitFailure     = 1 // failed reading, parsing, or writing a file
+	exitUsage       = 2 // bad command-line usage
+	exitUnformatted = 3 // -check found files whose formatting differs
 )
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]...\nPretty-print an HTML5 document from stdin.\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTION]... [PATH]...\n"+
+			"Pretty-print HTML5 documents from stdin or the supplied files or directories.\n\n", os.Args[0])
 		flag.PrintDefaults()
 	}
-	indent := flag.String("indent", "  ", "String to use for each level of indenting")
-	wrap := flag.Int("wrap", 120, "Line wrap length")
+	var p processor
+	flag.StringVar(&p.opts.Indent, "indent", "  ", "String to use for each level of indenting")
+	flag.IntVar(&p.opts.Wrap, "wrap", 120, "Line wrap length")
+	flag.IntVar(&p.opts.TabWidth, "tab-width", 8, "Columns between tab stops when computing line lengths (0 counts tabs as one column)")
+	flag.BoolVar(&p.opts.StripComments, "strip-comments", false, "Remove comments")
+	flag.BoolVar(&p.opts.WrapComments, "wrap-comments", false, "Wrap comments instead of printing them verbatim")
+	flag.BoolVar(&p.opts.SafeWhitespace, "safe-whitespace", false,
+		"Use default CSS display values to avoid adding visible whitespace between inline-level elements")
+	flag.BoolVar(&p.opts.CSSDisplay, "css-display", false,
+		"Use display properties from <style> elements and style attributes to decide layout")
+	flag.BoolVar(&p.opts.ReindentScripts, "reindent-scripts", false, "Reindent JavaScript in <script> elements")
+	flag.BoolVar(&p.opts.FormatCSS, "format-css", false, "Format CSS in <style> elements and style attributes")
+	flag.BoolVar(&p.opts.FormatJSON, "format-json", false, "Format JSON and JSON-LD in <script> elements")
+	flag.StringVar((*string)(&p.opts.CloseTags), "close-tags", "",
+		`Closing tag mode: "default" (omit closing tags in the omitClose class), "always", or "omitOptional"`)
+	flag.BoolVar(&p.fragment, "fragment", false, "Treat input as a fragment instead of a full document")
+	flag.StringVar(&p.context, "context", "body", "Context element for parsing fragments")
+	flag.BoolVar(&p.minify, "minify", false, "Minify instead of pretty-printing")
+	flag.BoolVar(&p.list, "l", false, "List files whose formatting differs")
+	flag.BoolVar(&p.write, "w", false, "Write result to (source) file instead of stdout")
+	flag.BoolVar(&p.diff, "d", false, "Display diffs instead of rewriting files")
+	flag.BoolVar(&p.check, "check", false,
+		fmt.Sprintf("Report files whose formatting differs and exit with status %d", exitUnformatted))
+	flag.BoolVar(&p.verify, "verify", false, "Check that formatted output is equivalent to the input")
+	exts := flag.String("ext", ".html,.htm", "Comma-separated extensions of files to format in directories")
+	var excludes listFlag
+	flag.Var(&excludes, "exclude", "gitignore-style pattern of paths to skip in directories (repeatable)")
+	ignoreFiles := flag.String("ignore-file", ".htmlprettyignore",
+		"Comma-separated names of gitignore-style files listing paths to skip in directories")
+	flag.BoolVar(&p.editorConfig, "editorconfig", false, "Read indent and line length settings from .editorconfig files")
+	jobs := flag.Int("j", runtime.GOMAXPROCS(0), "Number of files to format concurrently")
+	config := flag.String("config", "", "JSON or TOML file containing options (overrides "+
+		strings.Join(configNames, " and ")+" files, but overridden by flags)")
+	tags := make(tagsFlag)
+	flag.Var(tags, "tags", `Changes to a tag class, e.g. "inline=my-icon,-span" (repeatable)`)
 	flag.Parse()
 
-	node, err := html.Parse(os.Stdin)
-	if err != nil {
-		fmt.Fprint(os.Stderr, "Failed parsing HTML: ", err)
-		os.Exit(1)
+	if *config != "" {
+		var err error
+		if p.config, err = readConfig(*config); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed reading config:", err)
+			os.Exit(exitFailure)
+		}
+	}
+	var err error
+	if p.overrides, err = flagOverrides(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed encoding flags:", err)
+		os.Exit(exitFailure)
+	}
+	p.tags = tags
+
+	w := walker{
+		exts:        parseExts(*exts),
+		excludes:    excludes,
+		ignoreFiles: splitList(*ignoreFiles),
+	}
+
+	if *jobs < 1 {
+		fmt.Fprintln(os.Stderr, "-j must be positive")
+		os.Exit(exitUsage)
 	}
-	if err := htmlpretty.Print(os.Stdout, node, *indent, *wrap); err != nil {
-		fmt.Fprint(os.Stderr, "Failed printing HTML: ", err)
-		os.Exit(1)
+	if p.write && flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Can't use -w with standard input")
+		os.Exit(exitUsage)
+	}
+
+	var failed, changed int
+	if flag.NArg() == 0 {
+		ch, err := p.processFile("<standard input>", os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+		}
+		if ch {
+			changed++
+		}
+	}
+
+	var files []string
+	for _, arg := range flag.Args() {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+		if !fi.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		found, err := w.find(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+		}
+		files = append(files, found...)
+	}
+	f, c := p.processFiles(files, *jobs, os.Stdout, os.Stderr)
+	failed += f
+	changed += c
+
+	switch {
+	case failed > 0:
+		if failed > 1 {
+			fmt.Fprintf(os.Stderr, "Encountered %d errors\n", failed)
+		}
+		os.Exit(exitFailure)
+	case p.check && changed > 0:
+		os.Exit(exitUnformatted)
 	}
 }
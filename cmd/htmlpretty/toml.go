@@ -0,0 +1,306 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML parses the subset of TOML (https://toml.io/) needed for config files:
+// comments, tables, (dotted) keys, and string, integer, boolean, and array values.
+// The returned map can be passed to json.Marshal.
+func parseTOML(s string) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+	t := tomlParser{s: s, line: 1}
+	for {
+		t.skipSpace(true)
+		if t.done() {
+			return root, nil
+		}
+
+		if t.peek() == '[' {
+			t.pos++
+			if t.peek() == '[' {
+				return nil, t.errorf("arrays of tables are unsupported")
+			}
+			keys, err := t.key()
+			if err != nil {
+				return nil, err
+			}
+			if t.skipSpace(false); t.peek() != ']' {
+				return nil, t.errorf("expected ']'")
+			}
+			t.pos++
+			if table, err = t.table(root, keys); err != nil {
+				return nil, err
+			}
+		} else {
+			keys, err := t.key()
+			if err != nil {
+				return nil, err
+			}
+			if t.skipSpace(false); t.peek() != '=' {
+				return nil, t.errorf("expected '='")
+			}
+			t.pos++
+			t.skipSpace(false)
+			val, err := t.value()
+			if err != nil {
+				return nil, err
+			}
+			dst, err := t.table(table, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			if _, ok := dst[last]; ok {
+				return nil, t.errorf("duplicate key %q", last)
+			}
+			dst[last] = val
+		}
+
+		// Only a comment can follow on the same line.
+		if t.skipSpace(false); !t.done() && t.peek() != '\n' {
+			return nil, t.errorf("unexpected %q", t.peek())
+		}
+	}
+}
+
+// tomlParser holds the state of parseTOML.
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (t *tomlParser) done() bool { return t.pos >= len(t.s) }
+
+func (t *tomlParser) peek() byte {
+	if t.done() {
+		return 0
+	}
+	return t.s[t.pos]
+}
+
+func (t *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace advances past spaces, tabs, and comments.
+// If newlines is true, newlines are also skipped.
+func (t *tomlParser) skipSpace(newlines bool) {
+	for !t.done() {
+		switch c := t.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			t.pos++
+		case c == '\n' && newlines:
+			t.pos++
+			t.line++
+		case c == '#':
+			for !t.done() && t.peek() != '\n' {
+				t.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// table returns the table reached by following keys from m, creating tables as needed.
+func (t *tomlParser) table(m map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case nil:
+			sub := make(map[string]interface{})
+			m[k] = sub
+			m = sub
+		case map[string]interface{}:
+			m = v
+		default:
+			return nil, t.errorf("key %q is not a table", k)
+		}
+	}
+	return m, nil
+}
+
+// key parses a possibly-dotted key.
+func (t *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		t.skipSpace(false)
+		var k string
+		switch c := t.peek(); {
+		case c == '"' || c == '\'':
+			v, err := t.str()
+			if err != nil {
+				return nil, err
+			}
+			k = v
+		default:
+			start := t.pos
+			for !t.done() && isBareKeyChar(t.peek()) {
+				t.pos++
+			}
+			if t.pos == start {
+				return nil, t.errorf("expected key")
+			}
+			k = t.s[start:t.pos]
+		}
+		keys = append(keys, k)
+		if t.skipSpace(false); t.peek() != '.' {
+			return keys, nil
+		}
+		t.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// value parses a string, integer, boolean, or array.
+func (t *tomlParser) value() (interface{}, error) {
+	switch c := t.peek(); {
+	case c == '"' || c == '\'':
+		return t.str()
+	case c == '[':
+		t.pos++
+		vals := []interface{}{}
+		for {
+			t.skipSpace(true)
+			if t.peek() == ']' {
+				t.pos++
+				return vals, nil
+			}
+			v, err := t.value()
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+			t.skipSpace(true)
+			switch t.peek() {
+			case ',':
+				t.pos++
+			case ']':
+			default:
+				return nil, t.errorf("expected ',' or ']' in array")
+			}
+		}
+	default:
+		start := t.pos
+		for !t.done() && (isBareKeyChar(t.peek()) || t.peek() == '+') {
+			t.pos++
+		}
+		switch v := t.s[start:t.pos]; v {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "":
+			return nil, t.errorf("expected value")
+		default:
+			n, err := parseTOMLInt(v)
+			if err != nil {
+				return nil, t.errorf("bad value %q", v)
+			}
+			return n, nil
+		}
+	}
+}
+
+// parseTOMLInt parses a TOML integer: a decimal number with an optional sign and without
+// leading zeros, or an unsigned hexadecimal, octal, or binary number with a "0x", "0o", or
+// "0b" prefix. Underscores are permitted between digits.
+func parseTOMLInt(s string) (int64, error) {
+	base := 10
+	if len(s) > 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+	}
+	digits := s
+	sign := ""
+	if base != 10 {
+		digits = s[2:]
+	} else if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		sign, digits = s[:1], s[1:]
+	}
+	if digits == "" || digits[0] == '_' || digits[len(digits)-1] == '_' || strings.Contains(digits, "__") {
+		return 0, errors.New("misplaced underscore")
+	}
+	digits = strings.Replace(digits, "_", "", -1)
+	if digits[0] == '+' || digits[0] == '-' {
+		return 0, errors.New("bad sign")
+	}
+	if base == 10 && len(digits) > 1 && digits[0] == '0' {
+		return 0, errors.New("leading zero")
+	}
+	return strconv.ParseInt(sign+digits, base, 64)
+}
+
+// str parses a single-line basic ("...") or literal ('...') string.
+func (t *tomlParser) str() (string, error) {
+	quote := t.peek()
+	t.pos++
+	var b strings.Builder
+	for {
+		if t.done() || t.peek() == '\n' {
+			return "", t.errorf("unterminated string")
+		}
+		c := t.peek()
+		t.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && quote == '"':
+			if t.done() {
+				return "", t.errorf("unterminated string")
+			}
+			e := t.peek()
+			t.pos++
+			switch e {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(e)
+			case 'u', 'U':
+				n := 4
+				if e == 'U' {
+					n = 8
+				}
+				if t.pos+n > len(t.s) {
+					return "", t.errorf("bad escape")
+				}
+				r, err := strconv.ParseUint(t.s[t.pos:t.pos+n], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(r)) {
+					return "", t.errorf("bad escape")
+				}
+				b.WriteRune(rune(r))
+				t.pos += n
+			default:
+				return "", t.errorf("bad escape '\\%c'", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
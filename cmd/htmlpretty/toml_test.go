@@ -0,0 +1,76 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	type m = map[string]interface{}
+	type a = []interface{}
+	for _, tc := range []struct {
+		src  string
+		want map[string]interface{}
+	}{
+		{"", m{}},
+		{"# comment\n\n", m{}},
+		{`indent = "\t"  # comment` + "\nwrap = 80\nstripComments = true\n",
+			m{"indent": "\t", "wrap": int64(80), "stripComments": true}},
+		{"a = 1_000\nb = -5\nc = +7\nd = 0\ne = 0x1F\nf = 0o17\ng = 0b101\n",
+			m{"a": int64(1000), "b": int64(-5), "c": int64(7), "d": int64(0),
+				"e": int64(31), "f": int64(15), "g": int64(5)}},
+		{`s = "a\"b\\c\n\u00e9\U0001F600"` + "\nl = 'C:\\path\\n'\n",
+			m{"s": "a\"b\\c\n\u00e9\U0001F600", "l": `C:\path\n`}},
+		{"list = [ 'a', \"b\",\n  'c', ]\nempty = []\nnested = [[1], [true]]\n",
+			m{"list": a{"a", "b", "c"}, "empty": a{}, "nested": a{a{int64(1)}, a{true}}}},
+		{"tags.inline.add = ['x']\n\"quoted key\" = 1\n",
+			m{"tags": m{"inline": m{"add": a{"x"}}}, "quoted key": int64(1)}},
+		{"root = true\n[tags.inline]\nadd = ['a']\n[tags.literal]\nremove = ['b']\n",
+			m{"root": true, "tags": m{"inline": m{"add": a{"a"}}, "literal": m{"remove": a{"b"}}}}},
+		{"[ a . b ]\nc = 1\n[a]\nd = 2\n", m{"a": m{"b": m{"c": int64(1)}, "d": int64(2)}}},
+	} {
+		got, err := parseTOML(tc.src)
+		if err != nil {
+			t.Errorf("parseTOML(%q) failed: %v", tc.src, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseTOML(%q) = %v; want %v", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	for _, src := range []string{
+		"a = 1\na = 2",      // duplicate key
+		"a.b = 1\na.b = 2",  // duplicate dotted key
+		"a = 1\na.b = 2",    // not a table
+		"[a]\nb = 1\n[a.b]", // not a table
+		"a = 010",           // leading zero
+		"a = 0x",            // missing digits
+		"a = 0X10",          // uppercase prefix
+		"a = -0x10",         // signed hex
+		"a = 1__0",          // double underscore
+		"a = _1",            // leading underscore
+		"a = 1_",            // trailing underscore
+		"a = 1.5",           // floats are unsupported
+		"a = yes",           // bad value
+		`a = "unterminated`, // unterminated string
+		"a = 'multi\nline'", // newline in string
+		`a = "\x41"`,        // bad escape
+		`a = "\uD800"`,      // surrogate
+		"a = [1, 2",         // unterminated array
+		"a = [1 2]",         // missing comma
+		"a = 1 b = 2",       // trailing content
+		"a",                 // missing '='
+		"= 1",               // missing key
+		"[a",                // unterminated table header
+		"[[a]]",             // arrays of tables
+		"a = ",              // missing value
+	} {
+		if got, err := parseTOML(src); err == nil {
+			t.Errorf("parseTOML(%q) = %v; wanted error", src, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walker finds files to format in directory trees.
+type walker struct {
+	exts        []string // lowercase file extensions to include, e.g. ".html"
+	excludes    []string // gitignore-style patterns relative to each walked directory
+	ignoreFiles []string // names of gitignore-style files to read from each directory
+}
+
+// find returns the files in the directory tree rooted at root that should be formatted.
+func (w *walker) find(root string) ([]string, error) {
+	var ignores ignoreList
+	for _, pat := range w.excludes {
+		if err := ignores.add(pat, root); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && ignores.ignored(p, fi.IsDir()) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.IsDir() {
+			// Rules from a directory's ignore files only apply within it, which ignoreList
+			// handles by checking each rule's base directory.
+			for _, name := range w.ignoreFiles {
+				if err := ignores.addFile(filepath.Join(p, name)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if w.included(fi.Name()) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// included returns true if a file with the supplied name should be formatted.
+func (w *walker) included(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range w.exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExts parses a comma-separated list of file extensions.
+// Leading dots are added if missing.
+func parseExts(s string) []string {
+	var exts []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.ToLower(strings.TrimSpace(e)); e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		exts = append(exts, e)
+	}
+	return exts
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalker_Find(t *testing.T) {
+	root, err := ioutil.TempDir("", "htmlpretty_walk_test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for p, data := range map[string]string{
+		".gitignore":       "*.htm\n!keep.htm\ngen/\n",
+		"a.html":           "",
+		"b.htm":            "",
+		"keep.htm":         "",
+		"gen/x.html":       "",
+		"other/c.html":     "",
+		"sub/.gitignore":   "c.html\n/d.html\n",
+		"sub/c.html":       "",
+		"sub/d.html":       "",
+		"sub/e.html":       "",
+		"sub/deep/c.html":  "",
+		"sub/deep/d.html":  "",
+		"sub/deep/f.txt":   "",
+		"sub/.hidden.html": "",
+	} {
+		p = filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := walker{
+		exts:        parseExts("html,.HTM"),
+		excludes:    []string{"other"},
+		ignoreFiles: []string{".gitignore"},
+	}
+	files, err := w.find(root)
+	if err != nil {
+		t.Fatal("find failed: ", err)
+	}
+	var got []string
+	for _, p := range files {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.html", "keep.htm", "sub/deep/d.html", "sub/e.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("find(%q) = %q; want %q", root, got, want)
+	}
+}
@@ -0,0 +1,354 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// stylesheet holds CSS rules that set the display property.
+// Only type, class, and ID selectors and descendant combinators are supported.
+type stylesheet struct {
+	rules []styleRule
+	cache map[*html.Node]styleResult
+}
+
+// styleRule is a single selector and the display value that it sets.
+type styleRule struct {
+	sel    []compoundSelector // ancestors first, separated by descendant combinators
+	disp   display
+	weight int // see styleWeight
+}
+
+// styleResult holds the display value computed for an element.
+type styleResult struct {
+	disp display
+	ok   bool // false if no rules matched
+}
+
+// compoundSelector matches elements with a tag name (unless empty), an ID (unless empty),
+// and a set of classes.
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+// Weights used to order declarations: important declarations beat everything else,
+// declarations in style attributes beat rules in stylesheets, and more-specific
+// selectors beat less-specific ones. Later rules win ties.
+const (
+	importantWeight   = 1 << 30
+	inlineStyleWeight = 1 << 24
+)
+
+// styleWeight returns the weight of a selector with the supplied numbers of IDs,
+// classes, and tag names.
+func styleWeight(ids, classes, tags int) int {
+	limit := func(v int) int {
+		if v > 255 {
+			return 255
+		}
+		return v
+	}
+	return limit(ids)<<16 | limit(classes)<<8 | limit(tags)
+}
+
+// newStylesheet returns a stylesheet containing the rules from all <style> elements
+// in the trees containing nodes.
+func newStylesheet(nodes []*html.Node) *stylesheet {
+	ss := &stylesheet{cache: make(map[*html.Node]styleResult)}
+	seen := make(map[*html.Node]struct{})
+	for _, n := range nodes {
+		for n.Parent != nil {
+			n = n.Parent
+		}
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		ss.addStyleElements(n)
+	}
+	return ss
+}
+
+// addStyleElements adds rules from <style> elements in the tree rooted at n.
+func (ss *stylesheet) addStyleElements(n *html.Node) {
+	if n.Type == html.ElementNode && n.Data == "style" && n.Namespace == "" {
+		if typ := attrValue(n, "type"); typ == "" || strings.EqualFold(typ, "text/css") {
+			var b strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					b.WriteString(c.Data)
+				}
+			}
+			ss.parse(b.String())
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ss.addStyleElements(c)
+	}
+}
+
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parse adds the rules in css to ss.
+// At-rules like @media are skipped, since their conditions can't be evaluated.
+func (ss *stylesheet) parse(css string) {
+	css = cssComment.ReplaceAllString(css, " ")
+	for len(css) > 0 {
+		i := cssIndexAny(css, "{;}")
+		if i < 0 {
+			return
+		}
+		prelude := strings.TrimSpace(css[:i])
+		if css[i] != '{' {
+			// Stray semicolons and braces, or statement at-rules like @import.
+			css = css[i+1:]
+			continue
+		}
+		end := cssBlockEnd(css, i)
+		block := css[i+1 : end]
+		if end < len(css) {
+			end++
+		}
+		css = css[end:]
+		if strings.HasPrefix(prelude, "@") {
+			continue
+		}
+		disp, important, ok := parseDisplayDecl(block)
+		if !ok {
+			continue
+		}
+		for _, s := range strings.Split(prelude, ",") {
+			sel, weight, ok := parseSelector(s)
+			if !ok {
+				continue
+			}
+			if important {
+				weight += importantWeight
+			}
+			ss.rules = append(ss.rules, styleRule{sel, disp, weight})
+		}
+	}
+}
+
+// display returns the display value set for n by ss or by n's style attribute.
+func (ss *stylesheet) display(n *html.Node) (display, bool) {
+	if res, ok := ss.cache[n]; ok {
+		return res.disp, res.ok
+	}
+	var res styleResult
+	best := -1
+	for _, r := range ss.rules {
+		if r.weight >= best && r.matches(n) {
+			res = styleResult{r.disp, true}
+			best = r.weight
+		}
+	}
+	if style := attrValue(n, "style"); style != "" {
+		if disp, important, ok := parseDisplayDecl(style); ok {
+			weight := inlineStyleWeight
+			if important {
+				weight += importantWeight
+			}
+			if weight >= best {
+				res = styleResult{disp, true}
+			}
+		}
+	}
+	ss.cache[n] = res
+	return res.disp, res.ok
+}
+
+// matches returns true if r's selector matches n.
+func (r *styleRule) matches(n *html.Node) bool {
+	last := len(r.sel) - 1
+	if !r.sel[last].matches(n) {
+		return false
+	}
+	// Descendant combinators can be matched greedily against the nearest ancestors.
+	i := last - 1
+	for a := n.Parent; a != nil && i >= 0; a = a.Parent {
+		if r.sel[i].matches(a) {
+			i--
+		}
+	}
+	return i < 0
+}
+
+// matches returns true if n is an element matched by cs.
+func (cs *compoundSelector) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if cs.tag != "" && cs.tag != n.Data {
+		return false
+	}
+	if cs.id != "" && attrValue(n, "id") != cs.id {
+		return false
+	}
+	if len(cs.classes) > 0 {
+		classes := strings.Fields(attrValue(n, "class"))
+	ClassLoop:
+		for _, want := range cs.classes {
+			for _, c := range classes {
+				if c == want {
+					continue ClassLoop
+				}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// cssIdent matches a (simplified) CSS identifier.
+var cssIdent = regexp.MustCompile(`^-?[_a-zA-Z][-_a-zA-Z0-9]*`)
+
+// parseSelector parses s, a selector consisting of compound selectors separated by
+// whitespace. ok is false if s uses unsupported syntax.
+func parseSelector(s string) (sel []compoundSelector, weight int, ok bool) {
+	var ids, classes, tags int
+	for _, part := range strings.Fields(s) {
+		var cs compoundSelector
+		if part == "*" {
+			sel = append(sel, cs)
+			continue
+		}
+		if m := cssIdent.FindString(part); m != "" {
+			cs.tag = strings.ToLower(m)
+			part = part[len(m):]
+			tags++
+		} else if strings.HasPrefix(part, "*") {
+			part = part[1:]
+		}
+		for part != "" {
+			prefix := part[0]
+			m := cssIdent.FindString(part[1:])
+			if m == "" || (prefix != '.' && prefix != '#') {
+				return nil, 0, false
+			}
+			if prefix == '.' {
+				cs.classes = append(cs.classes, m)
+				classes++
+			} else {
+				cs.id = m
+				ids++
+			}
+			part = part[1+len(m):]
+		}
+		sel = append(sel, cs)
+	}
+	if len(sel) == 0 {
+		return nil, 0, false
+	}
+	return sel, styleWeight(ids, classes, tags), true
+}
+
+// parseDisplayDecl returns the last valid display value in decls, a semicolon-separated
+// list of CSS declarations. ok is false if no display value was found.
+func parseDisplayDecl(decls string) (disp display, important, ok bool) {
+	for _, decl := range strings.Split(decls, ";") {
+		i := strings.IndexByte(decl, ':')
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(decl[:i]), "display") {
+			continue
+		}
+		val := strings.ToLower(strings.TrimSpace(decl[i+1:]))
+		imp := false
+		if j := strings.Index(val, "!"); j >= 0 {
+			imp = strings.TrimSpace(val[j+1:]) == "important"
+			val = strings.TrimSpace(val[:j])
+		}
+		if d, valid := parseDisplay(val); valid && (imp || !important) {
+			disp, important, ok = d, imp, true
+		}
+	}
+	return disp, important, ok
+}
+
+// parseDisplay converts a CSS display value to a display.
+// Both single-keyword and multi-keyword (e.g. "inline flow-root") values are supported.
+func parseDisplay(val string) (display, bool) {
+	switch val {
+	case "none":
+		return noneDisplay, true
+	case "inline", "ruby", "contents":
+		return inlineDisplay, true
+	case "inline-block", "inline-flex", "inline-grid", "inline-table", "inline list-item":
+		return inlineBlockDisplay, true
+	case "table", "table-row", "table-cell", "table-row-group", "table-header-group",
+		"table-footer-group", "table-column", "table-column-group":
+		return tableDisplay, true
+	}
+	words := strings.Fields(val)
+	if len(words) == 0 {
+		return 0, false
+	}
+	switch words[0] {
+	case "block", "flex", "grid", "flow-root", "list-item", "table-caption", "run-in":
+		return blockDisplay, true
+	case "inline":
+		if len(words) == 2 && (words[1] == "flow" || words[1] == "ruby") {
+			return inlineDisplay, true
+		}
+		return inlineBlockDisplay, true
+	}
+	return 0, false
+}
+
+// cssIndexAny is like strings.IndexAny, but skips quoted strings.
+func cssIndexAny(s, chars string) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.IndexByte(chars, c) >= 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// cssBlockEnd returns the index of the brace closing the block opened at s[start],
+// or len(s) if the block is unterminated.
+func cssBlockEnd(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); {
+		j := cssIndexAny(s[i:], "{}")
+		if j < 0 {
+			break
+		}
+		i += j
+		if s[i] == '{' {
+			depth++
+		} else if depth--; depth == 0 {
+			return i
+		}
+		i++
+	}
+	return len(s)
+}
+
+// attrValue returns the value of n's attribute named key, or an empty string if
+// the attribute isn't present.
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
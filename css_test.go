@@ -0,0 +1,74 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestStylesheet_Display(t *testing.T) {
+	const doc = `<style>
+/* comment { display: none } */
+nav a, .blk { display: block }
+@media print { span { display: none } }
+@import "foo.css";
+.x > b, a:hover, [title] { display: none }
+div .a { display: inline }
+div#main .a { display: inline flow-root }
+b.imp { display: table-cell !important }
+i { display: grid; display: bogus }
+</style>
+<style type="text/plain">i { display: none }</style>
+<nav><a id="nav-a">a</a></nav>
+<div id="main"><p><span id="span-a" class="a">a</span></p></div>
+<div><span id="span-b" class="a b">b</span></div>
+<p class="x"><b id="b">b</b><b id="b-imp" class="imp" style="display:inline">b</b></p>
+<i id="i">i</i><em id="em-style" style="display: none; color: red">em</em>
+<span id="blk" class="blk">blk</span><span id="plain">plain</span>`
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("Parse failed:", err)
+	}
+	ss := newStylesheet([]*html.Node{root})
+
+	for _, tc := range []struct {
+		id   string
+		disp display
+		ok   bool
+	}{
+		{"nav-a", blockDisplay, true},
+		{"span-a", inlineBlockDisplay, true},
+		{"span-b", inlineDisplay, true},
+		{"b", 0, false},
+		{"b-imp", tableDisplay, true},
+		{"i", blockDisplay, true},
+		{"em-style", noneDisplay, true},
+		{"blk", blockDisplay, true},
+		{"plain", 0, false},
+	} {
+		n := findByID(root, tc.id)
+		if n == nil {
+			t.Fatalf("Didn't find #%v", tc.id)
+		}
+		if disp, ok := ss.display(n); disp != tc.disp || ok != tc.ok {
+			t.Errorf("display(#%v) = %v, %v; want %v, %v", tc.id, disp, ok, tc.disp, tc.ok)
+		}
+	}
+}
+
+// findByID returns the element in the tree rooted at n with the supplied ID.
+func findByID(n *html.Node, id string) *html.Node {
+	if n.Type == html.ElementNode && attrValue(n, "id") == id {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if f := findByID(c, id); f != nil {
+			return f
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"errors"
+	"strings"
+)
+
+// cssItem is a statement (e.g. a declaration or "@import" rule), a rule with a block,
+// or a comment in a stylesheet.
+type cssItem struct {
+	text    string    // prelude for rules, the full text otherwise (without a trailing semicolon)
+	block   []cssItem // contents of the block for rules
+	rule    bool      // true if the item has a block
+	comment bool      // true if the item is a comment
+	blank   bool      // true if the item was preceded by a blank line
+}
+
+// cssParser splits a stylesheet into cssItems.
+type cssParser struct {
+	s   string
+	pos int
+}
+
+// parseCSS splits src into cssItems. An error is returned for unbalanced braces and
+// unterminated strings and comments.
+func parseCSS(src string) ([]cssItem, error) {
+	cp := cssParser{s: src}
+	return cp.items(false)
+}
+
+// items parses items until the end of the input or, if nested is true, the end of the
+// current block.
+func (cp *cssParser) items(nested bool) ([]cssItem, error) {
+	var items []cssItem
+	start := cp.pos
+	depth := 0 // parenthesis depth, e.g. in "url(a;b)"
+
+	// addItem adds s as an item if it isn't blank.
+	addItem := func(s string, item cssItem) {
+		if strings.TrimSpace(s) == "" {
+			return
+		}
+		item.blank = strings.Count(s[:len(s)-len(strings.TrimLeft(s, cssSpace))], "\n") >= 2
+		if item.text == "" {
+			item.text = strings.TrimSpace(s)
+		}
+		items = append(items, item)
+	}
+
+	for cp.pos < len(cp.s) {
+		switch c := cp.s[cp.pos]; {
+		case strings.HasPrefix(cp.s[cp.pos:], "/*"):
+			end := strings.Index(cp.s[cp.pos+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment")
+			}
+			end += cp.pos + 4
+			// Comments between items are items themselves. Others are left in their items.
+			if prev := cp.s[start:cp.pos]; strings.TrimSpace(prev) == "" {
+				addItem(cp.s[start:end], cssItem{comment: true})
+				start = end
+			}
+			cp.pos = end
+			continue
+		case c == '"' || c == '\'':
+			end := cssStringEnd(cp.s, cp.pos)
+			if end < 0 {
+				return nil, errors.New("unterminated string")
+			}
+			cp.pos = end
+			continue
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == '{' && depth == 0:
+			prelude := cp.s[start:cp.pos]
+			cp.pos++
+			block, err := cp.items(true)
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(prelude) == "" {
+				return nil, errors.New("block without prelude")
+			}
+			addItem(prelude, cssItem{block: block, rule: true})
+			start = cp.pos
+			continue
+		case c == ';' && depth == 0:
+			addItem(cp.s[start:cp.pos], cssItem{})
+			start = cp.pos + 1
+		case c == '}' && depth == 0:
+			if !nested {
+				return nil, errors.New("unexpected '}'")
+			}
+			addItem(cp.s[start:cp.pos], cssItem{})
+			cp.pos++
+			return items, nil
+		}
+		cp.pos++
+	}
+	if nested {
+		return nil, errors.New("unterminated block")
+	}
+	addItem(cp.s[start:], cssItem{})
+	return items, nil
+}
+
+// cssSpace contains CSS whitespace characters.
+const cssSpace = " \t\n\r\f"
+
+// cssStringEnd returns the index after the end of the quoted string starting at s[start],
+// or -1 if the string is unterminated.
+func cssStringEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// formatCSS returns stylesheet src with each statement and declaration on its own line,
+// prefixed by indent. The contents of blocks are additionally indented by unit.
+// Blank lines between items are preserved.
+func formatCSS(src, indent, unit string) (string, error) {
+	items, err := parseCSS(src)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	writeCSSItems(&b, items, indent, unit)
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// writeCSSItems writes items to b, one per line.
+func writeCSSItems(b *strings.Builder, items []cssItem, indent, unit string) {
+	for i, it := range items {
+		if it.blank && i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(indent)
+		switch {
+		case it.comment:
+			b.WriteString(it.text)
+		case it.rule:
+			b.WriteString(strings.Join(splitCSS(collapseCSS(it.text), ','), ", "))
+			if len(it.block) == 0 {
+				b.WriteString(" {}")
+			} else {
+				b.WriteString(" {\n")
+				writeCSSItems(b, it.block, indent+unit, unit)
+				b.WriteString(indent + "}")
+			}
+		default:
+			b.WriteString(formatDecl(it.text) + ";")
+		}
+		b.WriteString("\n")
+	}
+}
+
+// formatStyleAttr returns the declarations in val, the value of a style attribute,
+// formatted as e.g. "color: red; margin: 0".
+func formatStyleAttr(val string) (string, error) {
+	items, err := parseCSS(val)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(items))
+	for _, it := range items {
+		switch {
+		case it.rule:
+			return "", errors.New("unexpected block")
+		case it.comment:
+			parts = append(parts, it.text)
+		default:
+			parts = append(parts, formatDecl(it.text))
+		}
+	}
+	// Avoid putting semicolons after comments.
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			if items[i-1].comment {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("; ")
+			}
+		}
+		b.WriteString(p)
+	}
+	return b.String(), nil
+}
+
+// formatDecl normalizes the spacing in s, a declaration like "color:red" or a statement
+// like "@import url(foo.css)".
+func formatDecl(s string) string {
+	s = collapseCSS(s)
+	if strings.HasPrefix(s, "@") {
+		return s
+	}
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return s
+	}
+	return strings.TrimSpace(s[:i]) + ": " + strings.TrimSpace(s[i+1:])
+}
+
+// collapseCSS trims s and replaces runs of whitespace outside of strings and comments
+// with single spaces.
+func collapseCSS(s string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case strings.IndexByte(cssSpace, c) >= 0:
+			space = true
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := cssStringEnd(s, i)
+			if end < 0 {
+				end = len(s)
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(s[i:end])
+			i = end
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 4
+			}
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(s[i:end])
+			i = end
+		default:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteByte(c)
+			i++
+		}
+		space = false
+	}
+	return b.String()
+}
+
+// splitCSS splits s at sep characters that aren't in strings or parentheses
+// and trims whitespace from each part.
+func splitCSS(s string, sep byte) []string {
+	var parts []string
+	start, depth := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\'':
+			if end := cssStringEnd(s, i); end > 0 {
+				i = end - 1
+			}
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"testing"
+)
+
+func TestFormatCSS(t *testing.T) {
+	for _, tc := range []struct {
+		src, exp string
+		ok       bool
+	}{
+		{"a{color:red;margin:0 auto}", "  a {\n    color: red;\n    margin: 0 auto;\n  }", true},
+		{"a,\n  b:hover { }", "  a, b:hover {}", true},
+		{"/* c */ a{b:c}\n\n\nd{e:f}", "  /* c */\n  a {\n    b: c;\n  }\n\n  d {\n    e: f;\n  }", true},
+		{`@import url("x;y.css");a{content:"{  ;"}`, "  @import url(\"x;y.css\");\n  a {\n    content: \"{  ;\";\n  }", true},
+		{"@media (min-width:1px){a{b:c}}", "  @media (min-width:1px) {\n    a {\n      b: c;\n    }\n  }", true},
+		{"a{b:url(data:x;y)}", "  a {\n    b: url(data:x;y);\n  }", true},
+		{"", "", true},
+		{"a{b:c", "", false},
+		{"a}", "", false},
+		{"a{content:'x}", "", false},
+		{"/* x", "", false},
+	} {
+		got, err := formatCSS(tc.src, "  ", "  ")
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("formatCSS(%q) unexpectedly succeeded", tc.src)
+			}
+		} else if err != nil {
+			t.Errorf("formatCSS(%q) failed: %v", tc.src, err)
+		} else if got != tc.exp {
+			t.Errorf("formatCSS(%q) = %q; want %q", tc.src, got, tc.exp)
+		}
+	}
+}
+
+func TestFormatStyleAttr(t *testing.T) {
+	for _, tc := range []struct {
+		val, exp string
+		ok       bool
+	}{
+		{"color:red", "color: red", true},
+		{" color : red ;margin:0  1px; ", "color: red; margin: 0 1px", true},
+		{"/* c */color:red", "/* c */ color: red", true},
+		{"background:url('a;b')", "background: url('a;b')", true},
+		{"a{b:c}", "", false},
+	} {
+		got, err := formatStyleAttr(tc.val)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("formatStyleAttr(%q) unexpectedly succeeded", tc.val)
+			}
+		} else if err != nil {
+			t.Errorf("formatStyleAttr(%q) failed: %v", tc.val, err)
+		} else if got != tc.exp {
+			t.Errorf("formatStyleAttr(%q) = %q; want %q", tc.val, got, tc.exp)
+		}
+	}
+}
+
+func TestPrint_FormatCSS(t *testing.T) {
+	const frag = `<div><style>a{color:red}b{margin:0}</style><style>a{</style>` +
+		`<p style="color:red;margin :0">x</p></div>`
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  ", FormatCSS: true}, `<div>
+  <style>
+    a {
+      color: red;
+    }
+    b {
+      margin: 0;
+    }
+  </style>
+  <style>a{</style>
+  <p style="color: red; margin: 0">x</p>
+</div>
+`)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// display describes how an element is laid out, based on its CSS display value.
+type display int
+
+const (
+	blockDisplay       display = iota // block, list-item, etc.
+	inlineDisplay                     // inline, ruby, etc.
+	inlineBlockDisplay                // inline-block; whitespace at the edges of its contents isn't rendered
+	tableDisplay                      // table parts; whitespace between them isn't rendered
+	noneDisplay                       // none; the element isn't rendered
+)
+
+// defaultDisplays maps from tag names to their display values in the user agent stylesheet at
+// https://html.spec.whatwg.org/multipage/rendering.html. Unlisted elements are inline.
+var defaultDisplays = newDisplayMap(map[display]string{
+	blockDisplay: "address article aside blockquote body center dd details dialog dir div dl dt " +
+		"fieldset figcaption figure footer form frame frameset h1 h2 h3 h4 h5 h6 header hgroup hr " +
+		"html legend li listing main menu nav ol optgroup option p plaintext pre search section " +
+		"summary ul xmp",
+	inlineBlockDisplay: "button input marquee meter progress select textarea",
+	tableDisplay:       "caption col colgroup table tbody td tfoot th thead tr",
+	noneDisplay: "area base basefont datalist head link meta noembed noframes noscript param rp " +
+		"script style template title",
+})
+
+func newDisplayMap(m map[display]string) map[string]display {
+	dm := make(map[string]display)
+	for d, tags := range m {
+		for _, t := range strings.Fields(tags) {
+			dm[t] = d
+		}
+	}
+	return dm
+}
+
+// display returns the display value of n, which must be an element.
+// If p.opts.CSSDisplay is true, values set by stylesheets and style attributes are used.
+// Otherwise, if p.opts.SafeWhitespace is false, elements are either inline or block
+// depending on whether they're in p.inlineTags.
+func (p *printer) display(n *html.Node) display {
+	if p.styles != nil {
+		if d, ok := p.styles.display(n); ok {
+			return d
+		}
+	}
+	if p.displays == nil {
+		if p.inlineTags.has(n) {
+			return inlineDisplay
+		}
+		return blockDisplay
+	}
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == "hidden" && !strings.EqualFold(a.Val, "until-found") && n.Data != "embed" {
+			return noneDisplay
+		}
+	}
+	if d, ok := p.displays[n.Data]; ok {
+		return d
+	}
+	return inlineDisplay
+}
+
+// inlineLevel returns true if n is an element that's laid out in its parent's line
+// (i.e. it's inline or inline-block). Whitespace around it is rendered.
+// Returns false if n is nil.
+func (p *printer) inlineLevel(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	d := p.display(n)
+	return d == inlineDisplay || d == inlineBlockDisplay
+}
+
+// inlineContext returns true if whitespace at the start and end of n's contents is
+// rendered, i.e. n is an inline element. Returns false if n is nil.
+func (p *printer) inlineContext(n *html.Node) bool {
+	return n != nil && n.Type == html.ElementNode && p.display(n) == inlineDisplay
+}
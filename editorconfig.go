@@ -0,0 +1,65 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ApplyEditorConfig updates o using EditorConfig properties (see https://editorconfig.org/)
+// that apply to a file. Property names and values are case-insensitive.
+// indent_style, indent_size, tab_width, and max_line_length are used, and other
+// properties and invalid values are ignored.
+func (o *Options) ApplyEditorConfig(props map[string]string) {
+	get := func(name string) string {
+		for k, v := range props {
+			if strings.ToLower(k) == name {
+				return strings.ToLower(strings.TrimSpace(v))
+			}
+		}
+		return ""
+	}
+	num := func(name string) (int, bool) {
+		n, err := strconv.Atoi(get(name))
+		return n, err == nil && n > 0
+	}
+
+	tabWidth, hasTabWidth := num("tab_width")
+	indentSize, hasIndentSize := num("indent_size")
+	if get("indent_size") == "tab" && hasTabWidth {
+		indentSize, hasIndentSize = tabWidth, true
+	}
+	// tab_width defaults to indent_size.
+	if !hasTabWidth && hasIndentSize {
+		tabWidth, hasTabWidth = indentSize, true
+	}
+	if hasTabWidth {
+		o.TabWidth = tabWidth
+	}
+
+	switch get("indent_style") {
+	case "tab":
+		o.Indent = "\t"
+	case "space":
+		if !hasIndentSize {
+			if strings.Trim(o.Indent, " ") == "" && o.Indent != "" {
+				break // already indenting with spaces
+			}
+			indentSize = 2
+		}
+		o.Indent = strings.Repeat(" ", indentSize)
+	default:
+		// Without an indent style, only change the size of space-based indents.
+		if hasIndentSize && o.Indent != "" && strings.Trim(o.Indent, " ") == "" {
+			o.Indent = strings.Repeat(" ", indentSize)
+		}
+	}
+
+	if v := get("max_line_length"); v == "off" {
+		o.Wrap = 0
+	} else if n, ok := num("max_line_length"); ok {
+		o.Wrap = n
+	}
+}
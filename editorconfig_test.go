@@ -0,0 +1,38 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"testing"
+)
+
+func TestOptions_ApplyEditorConfig(t *testing.T) {
+	for _, tc := range []struct {
+		init  Options
+		props map[string]string
+		want  Options
+	}{
+		{Options{Indent: "  ", Wrap: 120}, map[string]string{}, Options{Indent: "  ", Wrap: 120}},
+		{Options{Indent: "  ", Wrap: 120}, map[string]string{"indent_style": "tab"},
+			Options{Indent: "\t", Wrap: 120}},
+		{Options{Indent: "  ", Wrap: 120}, map[string]string{"indent_style": "tab", "indent_size": "4"},
+			Options{Indent: "\t", Wrap: 120, TabWidth: 4}},
+		{Options{Indent: "\t"}, map[string]string{"indent_style": "space", "indent_size": "4"},
+			Options{Indent: "    ", TabWidth: 4}},
+		{Options{Indent: "\t"}, map[string]string{"indent_style": "space"}, Options{Indent: "  "}},
+		{Options{Indent: "    "}, map[string]string{"Indent_Style": "SPACE"}, Options{Indent: "    "}},
+		{Options{Indent: "  "}, map[string]string{"indent_size": "3"}, Options{Indent: "   ", TabWidth: 3}},
+		{Options{Indent: "\t"}, map[string]string{"indent_size": "tab", "tab_width": "8"},
+			Options{Indent: "\t", TabWidth: 8}},
+		{Options{Wrap: 120}, map[string]string{"max_line_length": "80"}, Options{Wrap: 80}},
+		{Options{Wrap: 120}, map[string]string{"max_line_length": "off"}, Options{Wrap: 0}},
+		{Options{Wrap: 120}, map[string]string{"max_line_length": "bogus"}, Options{Wrap: 120}},
+	} {
+		got := tc.init
+		got.ApplyEditorConfig(tc.props)
+		if got.Indent != tc.want.Indent || got.Wrap != tc.want.Wrap || got.TabWidth != tc.want.TabWidth {
+			t.Errorf("ApplyEditorConfig(%v) on %+v produced %+v; want %+v", tc.props, tc.init, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// EmbeddedFormatter formats the contents of elements like <script> and <style> that are
+// otherwise printed verbatim.
+type EmbeddedFormatter interface {
+	// Format returns a formatted version of src, the original contents of an element.
+	// Each non-blank line of the returned string should be prefixed by indent.
+	// If an error is returned, the original contents are printed unchanged.
+	Format(src, indent string) (string, error)
+}
+
+// EmbeddedFormatterFunc adapts a function to the EmbeddedFormatter interface.
+type EmbeddedFormatterFunc func(src, indent string) (string, error)
+
+// Format calls f(src, indent).
+func (f EmbeddedFormatterFunc) Format(src, indent string) (string, error) {
+	return f(src, indent)
+}
+
+// EmbeddedType identifies the elements whose contents are handled by an EmbeddedFormatter.
+type EmbeddedType struct {
+	// Tag is the element's tag name, e.g. "script" or "style".
+	Tag string
+	// Type is the MIME type from the element's type attribute, without parameters.
+	// Script elements without a type or with a JavaScript MIME type use "text/javascript",
+	// while module scripts use "module" (falling back to "text/javascript" if no formatter
+	// is registered for "module"). Style elements without a type use "text/css".
+	// Other elements without a type use an empty string.
+	Type string
+}
+
+// errSkip is returned by built-in formatters to print contents verbatim.
+var errSkip = errors.New("skipped")
+
+// jsMIMEType is the EmbeddedType.Type used for JavaScript.
+const jsMIMEType = "text/javascript"
+
+// JavaScript MIME type essences per https://mimesniff.spec.whatwg.org/#javascript-mime-type.
+var jsMIMETypes = map[string]struct{}{
+	"application/ecmascript":   {},
+	"application/javascript":   {},
+	"application/x-ecmascript": {},
+	"application/x-javascript": {},
+	"text/ecmascript":          {},
+	"text/javascript":          {},
+	"text/javascript1.0":       {},
+	"text/javascript1.1":       {},
+	"text/javascript1.2":       {},
+	"text/javascript1.3":       {},
+	"text/javascript1.4":       {},
+	"text/javascript1.5":       {},
+	"text/jscript":             {},
+	"text/livescript":          {},
+	"text/x-ecmascript":        {},
+	"text/x-javascript":        {},
+}
+
+// scriptType returns the lowercase MIME type from script element n's type attribute,
+// without parameters. jsMIMEType is returned for classic scripts and "module" for modules.
+func scriptType(n *html.Node) string {
+	t := strings.ToLower(strings.TrimSpace(attrValue(n, "type")))
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = strings.TrimSpace(t[:i])
+	}
+	if _, ok := jsMIMETypes[t]; ok || t == "" {
+		return jsMIMEType
+	}
+	return t
+}
+
+// embeddedType returns the EmbeddedType describing n.
+func embeddedType(n *html.Node) EmbeddedType {
+	if n.Data == "script" {
+		return EmbeddedType{n.Data, scriptType(n)}
+	}
+	t := strings.ToLower(strings.TrimSpace(attrValue(n, "type")))
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = strings.TrimSpace(t[:i])
+	}
+	if n.Data == "style" && t == "" {
+		t = cssMIMEType
+	}
+	return EmbeddedType{n.Data, t}
+}
+
+// cssMIMEType is the MIME type of stylesheets.
+const cssMIMEType = "text/css"
+
+// jsonMIMETypes contains the types of script elements formatted by Options.FormatJSON.
+var jsonMIMETypes = []string{"application/json", "application/ld+json"}
+
+// initFormatters initializes p.formatters using p.opts.
+func (p *printer) initFormatters() {
+	p.formatters = make(map[EmbeddedType]EmbeddedFormatter)
+	if p.opts.ReindentScripts {
+		p.formatters[EmbeddedType{"script", jsMIMEType}] = EmbeddedFormatterFunc(reindentScript)
+	}
+	if p.opts.FormatJSON {
+		f := EmbeddedFormatterFunc(func(src, indent string) (string, error) {
+			return formatJSON(src, indent, p.opts.Indent)
+		})
+		for _, t := range jsonMIMETypes {
+			p.formatters[EmbeddedType{"script", t}] = f
+		}
+	}
+	if p.opts.FormatCSS {
+		p.formatters[EmbeddedType{"style", cssMIMEType}] = EmbeddedFormatterFunc(
+			func(src, indent string) (string, error) { return formatCSS(src, indent, p.opts.Indent) })
+	}
+	for et, f := range p.opts.Formatters {
+		p.formatters[EmbeddedType{strings.ToLower(et.Tag), strings.ToLower(et.Type)}] = f
+	}
+}
+
+// formatter returns the formatter for n's contents, or nil if they should be printed verbatim.
+func (p *printer) formatter(n *html.Node) EmbeddedFormatter {
+	if n.Type != html.ElementNode || n.Namespace != "" || !p.literalTags.has(n) {
+		return nil
+	}
+	et := embeddedType(n)
+	if f := p.formatters[et]; f != nil {
+		return f
+	}
+	if et.Tag == "script" && et.Type == "module" {
+		return p.formatters[EmbeddedType{"script", jsMIMEType}]
+	}
+	return nil
+}
+
+// Elements whose text contents are not escaped by html.Render.
+var rawTextTags = newTagSet(strings.Fields("iframe noembed noframes noscript plaintext script style xmp"))
+
+// embedded returns the formatted contents of n, including a leading newline
+// and the indentation preceding the closing tag. level is n's indentation level.
+// false is returned if the contents should be printed verbatim.
+func (p *printer) embedded(n *html.Node, level int) (string, bool) {
+	f := p.formatter(n)
+	if f == nil || n.FirstChild == nil {
+		return "", false
+	}
+	// Text needs to be escaped unless n is a raw text element, so it isn't parsed as markup.
+	raw := n.Namespace == "" && rawTextTags.has(n)
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode && raw {
+			b.WriteString(c.Data)
+		} else if c.Type == html.TextNode {
+			b.WriteString(html.EscapeString(c.Data))
+		} else if err := html.Render(&b, c); err != nil {
+			return "", false
+		}
+	}
+
+	out, err := f.Format(b.String(), strings.Repeat(p.opts.Indent, level+1))
+	if err != nil {
+		return "", false
+	}
+	// Don't let the formatted contents end the element early.
+	if strings.Contains(strings.ToLower(out), "</"+n.Data) {
+		return "", false
+	}
+	return wrapContent(strings.TrimRight(out, " \t\n"), p.opts.Indent, level), true
+}
+
+// reindentScript is an EmbeddedFormatter that reindents JavaScript.
+// Single-line scripts are skipped, along with ones that may contain template literals,
+// which can span lines.
+func reindentScript(src, indent string) (string, error) {
+	if !strings.Contains(strings.TrimSpace(src), "\n") || strings.Contains(src, "`") {
+		return "", errSkip
+	}
+	return reindent(src, indent), nil
+}
+
+// wrapContent returns the formatted contents of an element at level, adding newlines
+// around them and indentation before the closing tag. An empty string is returned
+// if content is blank.
+func wrapContent(content, indent string, level int) string {
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	return "\n" + content + "\n" + strings.Repeat(indent, level)
+}
+
+// reindent removes leading and trailing blank lines and trailing whitespace from src,
+// replaces the leading whitespace shared by its lines with indent, and returns the result.
+// If the first line is non-blank (i.e. it followed an opening tag), it isn't used to
+// determine the shared whitespace.
+func reindent(src, indent string) string {
+	lines := strings.Split(src, "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t\f\r")
+	}
+	skipFirst := lines[0] != ""
+	if skipFirst {
+		lines[0] = strings.TrimLeft(lines[0], " \t\f")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+		skipFirst = false
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	prefix := ""
+	found := false
+	for i, ln := range lines {
+		if ln == "" || (i == 0 && skipFirst) {
+			continue
+		}
+		lead := ln[:len(ln)-len(strings.TrimLeft(ln, " \t\f"))]
+		if !found {
+			prefix, found = lead, true
+			continue
+		}
+		for !strings.HasPrefix(lead, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	for i, ln := range lines {
+		if ln != "" {
+			if !(i == 0 && skipFirst) {
+				ln = ln[len(prefix):]
+			}
+			lines[i] = indent + ln
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scriptEndTag matches text that would end a script element.
+var scriptEndTag = regexp.MustCompile(`(?i)</(script)`)
+
+// formatJSON is an EmbeddedFormatter that reindents the JSON value in src, with each line
+// prefixed by indent and nested values indented by unit.
+func formatJSON(src, indent, unit string) (string, error) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return "", errSkip
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(src), indent, unit); err != nil {
+		return "", err
+	}
+	// "</script" can only appear in strings, where "/" can be escaped.
+	return indent + scriptEndTag.ReplaceAllString(b.String(), `<\/$1`), nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReindent(t *testing.T) {
+	for _, tc := range []struct {
+		src, indent, exp string
+	}{
+		{"\n    a();\n    b();\n  ", "  ", "  a();\n  b();"},
+		{"\n\n  if (x) {\n    y();  \n\n  }\n\n", "\t", "\tif (x) {\n\t  y();\n\n\t}"},
+		{"a();\n      b();\n    c();", "  ", "  a();\n    b();\n  c();"},
+		{"\n\ta();\n  b();\n", "", "\ta();\n  b();"},
+		{"\n  \n", "  ", ""},
+	} {
+		if got := reindent(tc.src, tc.indent); got != tc.exp {
+			t.Errorf("reindent(%q, %q) = %q; want %q", tc.src, tc.indent, got, tc.exp)
+		}
+	}
+}
+
+func TestScriptType(t *testing.T) {
+	for _, tc := range []struct {
+		doc, exp string
+	}{
+		{"<script></script>", "text/javascript"},
+		{`<script type=""></script>`, "text/javascript"},
+		{`<script type="Application/JavaScript; charset=utf-8"></script>`, "text/javascript"},
+		{`<script type="module"></script>`, "module"},
+		{`<script type="application/ld+json"></script>`, "application/ld+json"},
+	} {
+		nodes, err := ParseFragment(strings.NewReader(tc.doc), "body")
+		if err != nil {
+			t.Fatal("ParseFragment failed:", err)
+		}
+		if got := scriptType(nodes[0]); got != tc.exp {
+			t.Errorf("scriptType(%q) = %q; want %q", tc.doc, got, tc.exp)
+		}
+	}
+}
+
+// upperFormatter is an EmbeddedFormatter that uppercases its input.
+type upperFormatter struct{}
+
+func (upperFormatter) Format(src, indent string) (string, error) {
+	if strings.Contains(src, "fail") {
+		return "", errors.New("failed")
+	}
+	return indent + strings.ToUpper(strings.TrimSpace(src)), nil
+}
+
+func TestPrint_ReindentScripts(t *testing.T) {
+	const frag = "<div><script>\n      a();\n        b();\n</script>" +
+		"<script>one()</script>" +
+		"<script>\n  let s = `x\n  y`;\n</script>" +
+		"<script type=\"text/template\">\n  <p>\n</script></div>"
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  ", ReindentScripts: true}, `<div>
+  <script>
+    a();
+      b();
+  </script>
+  <script>one()</script>
+  <script>
+  let s = `+"`x\n  y`"+`;
+</script>
+  <script type="text/template">
+  <p>
+</script>
+</div>
+`)
+
+	checkPrintFragment(t, "<div><script type=\"text/template\">\n  <p>\n</script>"+
+		"<script type=module>f()</script><script>\n  fail()\n</script></div>", "body", &Options{
+		Indent:          "  ",
+		ReindentScripts: true,
+		Formatters: map[EmbeddedType]EmbeddedFormatter{
+			{"script", "text/template"}:   upperFormatter{},
+			{"script", "text/javascript"}: upperFormatter{},
+		},
+	}, `<div>
+  <script type="text/template">
+    <P>
+  </script>
+  <script type="module">
+    F()
+  </script>
+  <script>
+  fail()
+</script>
+</div>
+`)
+}
+
+func TestPrint_Formatters(t *testing.T) {
+	var gotSrc, gotIndent string
+	record := EmbeddedFormatterFunc(func(src, indent string) (string, error) {
+		gotSrc, gotIndent = src, indent
+		return indent + "recorded", nil
+	})
+	const frag = `<div><style>a{b:c}</style><template><p>x</p></template>` +
+		`<script type="Application/LD+JSON; charset=utf-8">{}</script><script>f()</script></div>`
+	checkPrintFragment(t, frag, "body", &Options{
+		Indent:    "  ",
+		FormatCSS: true,
+		Tags:      map[TagClass]TagChanges{LiteralClass: {Add: []string{"template"}}},
+		Formatters: map[EmbeddedType]EmbeddedFormatter{
+			{"STYLE", "text/css"}: upperFormatter{},
+			{"template", ""}:      record,
+			{"script", "application/ld+json"}: EmbeddedFormatterFunc(func(src, indent string) (string, error) {
+				return indent + "</SCRIPT>", nil // rejected since it would end the element
+			}),
+		},
+	}, `<div>
+  <style>
+    A{B:C}
+  </style>
+  <template>
+    recorded
+  </template>
+  <script type="Application/LD+JSON; charset=utf-8">{}</script>
+  <script>f()</script>
+</div>
+`)
+	if gotSrc != "<p>x</p>" || gotIndent != "    " {
+		t.Errorf("Template formatter called with %q and %q; want %q and %q", gotSrc, gotIndent, "<p>x</p>", "    ")
+	}
+}
+
+func TestPrint_FormattersEscapeText(t *testing.T) {
+	var gotSrc string
+	record := EmbeddedFormatterFunc(func(src, indent string) (string, error) {
+		gotSrc = src
+		return indent + src, nil
+	})
+	checkPrintFragment(t, `<template>a &lt;b&gt; c</template>`, "body", &Options{
+		Indent:     "  ",
+		Tags:       map[TagClass]TagChanges{LiteralClass: {Add: []string{"template"}}},
+		Formatters: map[EmbeddedType]EmbeddedFormatter{{"template", ""}: record},
+	}, `<template>
+  a &lt;b&gt; c
+</template>
+`)
+	if want := "a &lt;b&gt; c"; gotSrc != want {
+		t.Errorf("Template formatter called with %q; want %q", gotSrc, want)
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	for _, tc := range []struct {
+		src, exp string
+		ok       bool
+	}{
+		{` {"a":[1,2],"b":{}} `, "  {\n    \"a\": [\n      1,\n      2\n    ],\n    \"b\": {}\n  }", true},
+		{`"x</Script>y"`, `  "x<\/Script>y"`, true},
+		{`{"a":1.50e3}`, "  {\n    \"a\": 1.50e3\n  }", true},
+		{`{"a":`, "", false},
+		{" ", "", false},
+	} {
+		got, err := formatJSON(tc.src, "  ", "  ")
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("formatJSON(%q) unexpectedly succeeded", tc.src)
+			}
+		} else if err != nil {
+			t.Errorf("formatJSON(%q) failed: %v", tc.src, err)
+		} else if got != tc.exp {
+			t.Errorf("formatJSON(%q) = %q; want %q", tc.src, got, tc.exp)
+		}
+	}
+}
+
+func TestPrint_FormatJSON(t *testing.T) {
+	const frag = `<div><script type="application/ld+json">{"@type":"Thing","name":"x"}</script>` +
+		`<script type="application/json">{bad</script><script>{"a":1}</script></div>`
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  ", FormatJSON: true}, `<div>
+  <script type="application/ld+json">
+    {
+      "@type": "Thing",
+      "name": "x"
+    }
+  </script>
+  <script type="application/json">{bad</script>
+  <script>{"a":1}</script>
+</div>
+`)
+}
@@ -0,0 +1,336 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Comments containing these directives prevent nodes from being reformatted:
+//
+//	<!-- htmlpretty-ignore -->
+//	<p>The next node is left unchanged.</p>
+//
+//	<!-- htmlpretty-ignore-start -->
+//	<p>Everything up to the end directive is left unchanged.</p>
+//	<!-- htmlpretty-ignore-end -->
+//
+// When the document is printed by FormatDocument or FormatFragment, the ignored region
+// is copied byte-for-byte from the original source. Otherwise, the ignored nodes are
+// written unformatted using html.Render.
+const (
+	ignoreDirective      = "htmlpretty-ignore"
+	ignoreStartDirective = "htmlpretty-ignore-start"
+	ignoreEndDirective   = "htmlpretty-ignore-end"
+)
+
+// FormatDocument parses src as an HTML document and pretty-prints it to w using opts.
+// Unlike PrintWithOptions, regions marked by ignore directives are reproduced exactly as
+// they appear in src. If opts is nil, the zero value of Options is used.
+func FormatDocument(w io.Writer, src []byte, opts *Options) error {
+	root, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(w, opts)
+	if err != nil {
+		return err
+	}
+	p.src = newDocumentSource(src, root)
+	if err := p.doc(root); err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// FormatFragment is like FormatDocument, but src is parsed as a fragment using
+// ParseFragment with the supplied context tag name and printed using PrintFragment.
+func FormatFragment(w io.Writer, src []byte, context string, opts *Options) error {
+	nodes, err := ParseFragment(bytes.NewReader(src), context)
+	if err != nil {
+		return err
+	}
+	p, err := newPrinter(w, opts)
+	if err != nil {
+		return err
+	}
+	p.src = newFragmentSource(src, context, nodes)
+	if err := p.fragment(nodes); err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// ignore checks if n is an ignore directive. If it is, n and the nodes that it applies to
+// are written, and the last node that was written is returned. Otherwise, nil is returned.
+func (p *printer) ignore(n *html.Node) *html.Node {
+	if n.Type != html.CommentNode {
+		return nil
+	}
+
+	// Find the last node to write verbatim.
+	var last *html.Node
+	switch strings.TrimSpace(n.Data) {
+	case ignoreDirective:
+		for last = n.NextSibling; last != nil && isSpaceText(last); last = last.NextSibling {
+		}
+	case ignoreStartDirective:
+		// If there's no end directive, ignore the rest of the siblings.
+		for last = n.NextSibling; last != nil && last.NextSibling != nil; last = last.NextSibling {
+			if last.Type == html.CommentNode && strings.TrimSpace(last.Data) == ignoreEndDirective {
+				break
+			}
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	raw, ok := p.src.between(n, last)
+	if !ok {
+		var b bytes.Buffer
+		for c := n.NextSibling; c != last.NextSibling; c = c.NextSibling {
+			if err := html.Render(&b, c); err != nil {
+				return nil
+			}
+		}
+		raw = b.String()
+	}
+
+	// Write the directive without a trailing newline, since any whitespace following it
+	// is included in the raw text.
+	if !p.opts.StripComments {
+		if !p.minify {
+			if !p.isInline(n) {
+				p.endl()
+			}
+			p.maybeIndent()
+		}
+		p.write("<!--" + n.Data + "-->")
+	}
+	p.write(raw)
+	if p.minify {
+		// Whitespace following the raw text may be rendered.
+		p.minTrim = false
+	} else if strings.HasSuffix(raw, "\n") {
+		p.lineStart = true
+	}
+	return last
+}
+
+// isIgnoreDirective returns true if n is a comment containing one of the ignore directives.
+func isIgnoreDirective(n *html.Node) bool {
+	if n.Type != html.CommentNode {
+		return false
+	}
+	switch strings.TrimSpace(n.Data) {
+	case ignoreDirective, ignoreStartDirective, ignoreEndDirective:
+		return true
+	}
+	return false
+}
+
+// isSpaceText returns true if n is a text node consisting only of whitespace.
+func isSpaceText(n *html.Node) bool {
+	return n.Type == html.TextNode && strings.Trim(n.Data, "\t\n\f\r ") == ""
+}
+
+// source holds the original text of a document.
+type source struct {
+	text     []byte
+	tokens   []sourceToken
+	comments map[*html.Node]int // indexes into tokens
+}
+
+// sourceToken describes a token in source.text.
+type sourceToken struct {
+	typ        html.TokenType
+	name       string // tag name for start and end tags
+	data       string // unescaped text for text and comment tokens
+	start, end int    // byte offsets into source.text
+}
+
+// Elements that the parser may insert without corresponding start tags in the source.
+var impliedTags = newTagSet(strings.Fields("html head body tbody tr colgroup"))
+
+// Elements whose contents' leading newlines are dropped by the parser.
+var newlineTags = newTagSet(strings.Fields("pre listing textarea"))
+
+// newDocumentSource calls newSource for a document that was parsed from text by html.Parse.
+func newDocumentSource(text []byte, root *html.Node) *source {
+	return newSource(text, func(b []byte) ([]*html.Node, error) {
+		root, err := html.Parse(bytes.NewReader(b))
+		return []*html.Node{root}, err
+	}, root)
+}
+
+// newFragmentSource calls newSource for nodes that were parsed from text by ParseFragment.
+func newFragmentSource(text []byte, context string, nodes []*html.Node) *source {
+	return newSource(text, func(b []byte) ([]*html.Node, error) {
+		return ParseFragment(bytes.NewReader(b), context)
+	}, nodes...)
+}
+
+// newSource tokenizes text and associates comment tokens with the comment nodes in the
+// trees rooted at roots, which must have been returned by passing text to parse.
+func newSource(text []byte, parse func([]byte) ([]*html.Node, error), roots ...*html.Node) *source {
+	s := &source{text: text, comments: make(map[*html.Node]int)}
+	z := html.NewTokenizer(bytes.NewReader(text))
+	for pos := 0; ; {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		t := sourceToken{typ: tt, start: pos}
+		pos += len(z.Raw())
+		t.end = pos
+		switch tt {
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			t.name = string(name)
+		case html.TextToken, html.CommentToken:
+			t.data = string(z.Text())
+		}
+		s.tokens = append(s.tokens, t)
+	}
+
+	// The parser can move and drop comments, so comments can't be matched up with tokens
+	// by their order or text. Instead, replace each comment with its token index and parse
+	// the text again: comments' contents don't affect the tree's structure, so the two
+	// trees can be walked in parallel.
+	var b bytes.Buffer
+	prev := 0
+	for i, t := range s.tokens {
+		// CDATA sections are reported as comments but parsed as text in foreign content.
+		if t.typ != html.CommentToken || bytes.HasPrefix(text[t.start:t.end], []byte("<![CDATA[")) {
+			continue
+		}
+		b.Write(text[prev:t.start])
+		b.WriteString("<!--" + strconv.Itoa(i) + "-->")
+		prev = t.end
+	}
+	b.Write(text[prev:])
+	marked, err := parse(b.Bytes())
+	if err != nil || len(marked) != len(roots) {
+		return s
+	}
+
+	var walk func(n, m *html.Node) bool
+	walk = func(n, m *html.Node) bool {
+		if n.Type != m.Type || (n.Type == html.ElementNode && n.Data != m.Data) {
+			return false
+		}
+		if n.Type == html.CommentNode {
+			if i, err := strconv.Atoi(m.Data); err == nil && i >= 0 && i < len(s.tokens) &&
+				s.tokens[i].typ == html.CommentToken {
+				s.comments[n] = i
+			}
+		}
+		c, d := n.FirstChild, m.FirstChild
+		for ; c != nil && d != nil; c, d = c.NextSibling, d.NextSibling {
+			if !walk(c, d) {
+				return false
+			}
+		}
+		return c == nil && d == nil
+	}
+	for i := range roots {
+		if !walk(roots[i], marked[i]) {
+			// Don't trust any of the positions if the trees differ.
+			s.comments = make(map[*html.Node]int)
+			break
+		}
+	}
+	return s
+}
+
+// between returns the original text following comment node from up through the end of
+// node to, which must be a later sibling. false is returned if s is nil or if the
+// source's tokens don't exactly describe the nodes after from up through to, e.g.
+// because the parser closed an element before its end tag.
+func (s *source) between(from, to *html.Node) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	fi, ok := s.comments[from]
+	if !ok {
+		return "", false
+	}
+
+	i := fi + 1
+	for n := from.NextSibling; ; n = n.NextSibling {
+		if n == nil {
+			return "", false
+		}
+		if i, ok = s.match(i, n); !ok {
+			return "", false
+		}
+		if n == to {
+			break
+		}
+	}
+	return string(s.text[s.tokens[fi].end:s.tokens[i-1].end]), true
+}
+
+// match checks that the tokens starting at index i describe node n and its descendants.
+// If they do, the index of the token following n is returned.
+func (s *source) match(i int, n *html.Node) (int, bool) {
+	switch n.Type {
+	case html.TextNode:
+		// The parser merges adjacent text, e.g. if the text contained a stray '<'.
+		want := n.Data
+		if newlineTags.has(n.Parent) && n.PrevSibling == nil && i < len(s.tokens) &&
+			strings.HasPrefix(s.tokens[i].data, "\n") {
+			want = "\n" + want
+		}
+		var text string
+		for ; i < len(s.tokens) && s.tokens[i].typ == html.TextToken && len(text) < len(want); i++ {
+			text += s.tokens[i].data
+		}
+		return i, text == want
+	case html.CommentNode:
+		ci, ok := s.comments[n]
+		return i + 1, ok && ci == i
+	case html.ElementNode:
+		name := strings.ToLower(n.Data) // foreign elements' names are adjusted by the parser
+		var t sourceToken
+		if i < len(s.tokens) {
+			t = s.tokens[i]
+		}
+		implied := false
+		switch {
+		case t.typ == html.SelfClosingTagToken && t.name == name:
+			return i + 1, n.FirstChild == nil
+		case t.typ == html.StartTagToken && t.name == name:
+			i++
+			if newlineTags.has(n) && n.FirstChild == nil && i < len(s.tokens) && s.tokens[i].data == "\n" {
+				i++
+			}
+		case impliedTags.has(n) && len(n.Attr) == 0:
+			implied = true
+		default:
+			return i, false
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			var ok bool
+			if i, ok = s.match(i, c); !ok {
+				return i, false
+			}
+		}
+		if n.Namespace == "" && defaultVoidTags.has(n) {
+			return i, true
+		}
+		if i < len(s.tokens) && s.tokens[i].typ == html.EndTagToken && s.tokens[i].name == name {
+			return i + 1, true
+		}
+		// Implied elements are also closed implicitly.
+		return i, implied
+	}
+	return i, false
+}
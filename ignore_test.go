@@ -0,0 +1,146 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const ignoreDoc = `<!DOCTYPE html>
+<html><head></head><body>
+<p>Formatted
+   text</p>
+<!-- htmlpretty-ignore -->
+<pre class=art>+--+--+
+|  |  |</pre   >
+<p>More   text</p>
+<div>
+<!-- htmlpretty-ignore-start -->
+<table><tr><td>A</td>
+           <td>B&amp;C</td></tr></table>
+<p>  Keep   me  </p>
+  <!-- htmlpretty-ignore-end -->
+<p>Formatted
+   again</p>
+</div>
+</body></html>`
+
+func TestFormatDocument_Ignore(t *testing.T) {
+	var b bytes.Buffer
+	if err := FormatDocument(&b, []byte(ignoreDoc), &Options{Indent: "  ", Wrap: 80}); err != nil {
+		t.Fatal("FormatDocument failed: ", err)
+	}
+	checkOutput(t, b.String(), `<!DOCTYPE html>
+<html>
+  <head></head>
+  <body>
+    <p>Formatted text</p>
+    <!-- htmlpretty-ignore -->
+<pre class=art>+--+--+
+|  |  |</pre   >
+    <p>More text</p>
+    <div>
+      <!-- htmlpretty-ignore-start -->
+<table><tr><td>A</td>
+           <td>B&amp;C</td></tr></table>
+<p>  Keep   me  </p>
+  <!-- htmlpretty-ignore-end -->
+      <p>Formatted again</p>
+    </div>
+  </body>
+</html>
+`)
+}
+
+func TestFormatFragment_Ignore(t *testing.T) {
+	var b bytes.Buffer
+	if err := FormatFragment(&b, []byte(`<p>Some
+  text</p>
+<!-- htmlpretty-ignore -->
+<p>Other
+  text</p>`), "", &Options{Indent: "  "}); err != nil {
+		t.Fatal("FormatFragment failed: ", err)
+	}
+	checkOutput(t, b.String(), `<p>Some text</p>
+<!-- htmlpretty-ignore -->
+<p>Other
+  text</p>
+`)
+}
+
+func TestPrint_IgnoreWithoutSource(t *testing.T) {
+	// Without the source, ignored nodes are rendered as parsed.
+	root, err := html.Parse(strings.NewReader(ignoreDoc))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	var b bytes.Buffer
+	if err := Print(&b, root, "  ", 80); err != nil {
+		t.Fatal("Print failed: ", err)
+	}
+	checkOutput(t, b.String(), `<!DOCTYPE html>
+<html>
+  <head></head>
+  <body>
+    <p>Formatted text</p>
+    <!-- htmlpretty-ignore -->
+<pre class="art">+--+--+
+|  |  |</pre>
+    <p>More text</p>
+    <div>
+      <!-- htmlpretty-ignore-start -->
+<table><tbody><tr><td>A</td>
+           <td>B&amp;C</td></tr></tbody></table>
+<p>  Keep   me  </p>
+  <!-- htmlpretty-ignore-end -->
+      <p>Formatted again</p>
+    </div>
+  </body>
+</html>
+`)
+}
+
+func TestFormatFragment_IgnoreMisnested(t *testing.T) {
+	// The parser closes the p element before the div, so the source text up through the
+	// stray </p> tag doesn't describe the ignored node.
+	var b bytes.Buffer
+	if err := FormatFragment(&b, []byte(`<!-- htmlpretty-ignore --><p>a <div>b</div></p>
+<!-- htmlpretty-ignore --><ul><li>One<li>Two</ul>
+<!-- htmlpretty-ignore --><pre>
+  x</pre>`), "", &Options{Indent: "  "}); err != nil {
+		t.Fatal("FormatFragment failed: ", err)
+	}
+	checkOutput(t, b.String(), `<!-- htmlpretty-ignore --><p>a </p>
+<div>b</div>
+<p></p>
+<!-- htmlpretty-ignore --><ul><li>One</li><li>Two</li></ul>
+<!-- htmlpretty-ignore --><pre>
+  x</pre>
+`)
+}
+
+func TestFormatDocument_IgnoreMovedComment(t *testing.T) {
+	// The second directive is moved after the body element by the parser, so it
+	// shouldn't be matched up with the third directive's position in the source.
+	var b bytes.Buffer
+	if err := FormatDocument(&b, []byte(`<body><!-- htmlpretty-ignore --><p>One  </p>
+</body><!-- htmlpretty-ignore --><p>Two  </p>
+<!-- htmlpretty-ignore --><p>Three  </p>`), &Options{Indent: "  "}); err != nil {
+		t.Fatal("FormatDocument failed: ", err)
+	}
+	checkOutput(t, b.String(), `<html>
+  <head></head>
+  <body>
+    <!-- htmlpretty-ignore --><p>One  </p>
+    <p>Two</p>
+    <!-- htmlpretty-ignore --><p>Three  </p>
+  </body>
+  <!-- htmlpretty-ignore -->
+</html>
+`)
+}
@@ -0,0 +1,344 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Minify writes a minified version of the supplied node (typically a document) to w.
+// Whitespace that isn't rendered is removed, other text is collapsed, comments other than
+// conditional comments and ignore directives are removed, attribute values are unquoted
+// where possible, and optional tags are omitted as described by OmitOptionalTags unless
+// Options.CloseTags is AlwaysCloseTags. The contents of elements in LiteralClass and KeepSpaceClass are preserved.
+//
+// Whitespace is always evaluated using the display values described by
+// Options.SafeWhitespace. Options that only affect pretty-printing (e.g. Indent and Wrap)
+// are ignored. If opts is nil, the zero value of Options is used. Regions marked by ignore
+// directives are written unminified using html.Render; use MinifyDocument to reproduce
+// them exactly.
+func Minify(w io.Writer, n *html.Node, opts *Options) error {
+	p, err := newMinifier(w, opts)
+	if err != nil {
+		return err
+	}
+	return p.minRoot(n)
+}
+
+// MinifyFragment is like Minify, but for a list of nodes (e.g. as returned by ParseFragment).
+func MinifyFragment(w io.Writer, nodes []*html.Node, opts *Options) error {
+	p, err := newMinifier(w, opts)
+	if err != nil {
+		return err
+	}
+	return p.minFragment(nodes)
+}
+
+// MinifyDocument parses src as an HTML document and minifies it to w using opts.
+// Like FormatDocument, regions marked by ignore directives are reproduced exactly as
+// they appear in src, and the directives are preserved.
+func MinifyDocument(w io.Writer, src []byte, opts *Options) error {
+	root, err := html.Parse(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	p, err := newMinifier(w, opts)
+	if err != nil {
+		return err
+	}
+	p.src = newDocumentSource(src, root)
+	return p.minRoot(root)
+}
+
+// MinifyFragmentSource is like MinifyDocument, but src is parsed as a fragment using
+// ParseFragment with the supplied context tag name and minified using MinifyFragment.
+func MinifyFragmentSource(w io.Writer, src []byte, context string, opts *Options) error {
+	nodes, err := ParseFragment(bytes.NewReader(src), context)
+	if err != nil {
+		return err
+	}
+	p, err := newMinifier(w, opts)
+	if err != nil {
+		return err
+	}
+	p.src = newFragmentSource(src, context, nodes)
+	return p.minFragment(nodes)
+}
+
+// minRoot minifies n (typically a document) for Minify and MinifyDocument.
+func (p *printer) minRoot(n *html.Node) error {
+	p.loadStyles([]*html.Node{n})
+	var err error
+	if n.Type == html.DocumentNode {
+		err = p.minSiblings(n.FirstChild, n.LastChild)
+	} else {
+		err = p.minNode(n)
+	}
+	if err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// minFragment minifies nodes for MinifyFragment and MinifyFragmentSource.
+func (p *printer) minFragment(nodes []*html.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	p.loadStyles(nodes)
+	var err error
+	if detach, ok := attach(nodes); ok {
+		defer detach()
+		err = p.minSiblings(nodes[0], nodes[len(nodes)-1])
+	} else {
+		for _, n := range nodes {
+			if err = p.minNode(n); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// newMinifier returns a printer for Minify and MinifyFragment.
+func newMinifier(w io.Writer, opts *Options) (*printer, error) {
+	var o Options
+	if opts != nil {
+		o = *opts
+	}
+	o.SafeWhitespace = true
+	p, err := newPrinter(w, &o)
+	if err != nil {
+		return nil, err
+	}
+	p.minify = true
+	p.minTrim = true
+	return p, nil
+}
+
+// minSiblings minifies the nodes from first to last (inclusive), which must be siblings.
+func (p *printer) minSiblings(first, last *html.Node) error {
+	for c := first; c != nil; c = c.NextSibling {
+		// If c is an ignore directive, skip the nodes that were written verbatim.
+		if end := p.ignore(c); end != nil {
+			c = end
+		} else if err := p.minNode(c); err != nil {
+			return err
+		}
+		if c == last {
+			break
+		}
+	}
+	return nil
+}
+
+// minNode minifies n by calling the appropriate method for its type.
+func (p *printer) minNode(n *html.Node) error {
+	switch n.Type {
+	case html.DoctypeNode:
+		p.write("<!DOCTYPE " + n.Data + ">")
+		p.minTrim = true
+	case html.ElementNode:
+		return p.minElement(n)
+	case html.TextNode:
+		p.minText(n)
+	case html.CommentNode:
+		if isConditionalComment(n) {
+			p.write("<!--" + n.Data + "-->")
+		}
+	case html.RawNode:
+		p.write(n.Data)
+	default:
+		return fmt.Errorf("unexpected node %q of type %d", n.Data, n.Type)
+	}
+	return nil
+}
+
+// minElement minifies element n and its contents.
+func (p *printer) minElement(n *html.Node) error {
+	if !p.omitOpen(n) {
+		p.write(p.minOpenTag(n))
+	}
+	disp := p.display(n)
+
+	if p.voidTags.has(n) {
+		switch disp {
+		case blockDisplay, tableDisplay:
+			p.minTrim = true
+		case inlineDisplay, inlineBlockDisplay:
+			p.minTrim = false
+		}
+		return nil
+	}
+
+	literal := p.literalTags.has(n)
+	if literal {
+		p.literalDepth++
+	}
+	keepSpace := p.keepSpaceTags.has(n)
+	if keepSpace {
+		p.keepSpaceDepth++
+	}
+	// Whitespace isn't collapsed across inline elements' boundaries, which is safe but
+	// keeps the output's text consistent with the original tree.
+	switch disp {
+	case inlineDisplay:
+		p.minTrim = false
+	case blockDisplay, tableDisplay, inlineBlockDisplay:
+		p.minTrim = true
+	}
+	if leadingNewlineTags.has(n) && n.FirstChild != nil && n.FirstChild.Type == html.TextNode &&
+		strings.HasPrefix(n.FirstChild.Data, "\n") {
+		p.write("\n")
+	}
+
+	if err := p.minSiblings(n.FirstChild, n.LastChild); err != nil {
+		return err
+	}
+
+	if n.Data == "plaintext" {
+		p.ended = true
+	}
+	if !p.omitClose(n) {
+		p.write("</" + n.Data + ">")
+	}
+	if literal {
+		p.literalDepth--
+	}
+	if keepSpace {
+		p.keepSpaceDepth--
+	}
+
+	switch disp {
+	case blockDisplay, tableDisplay:
+		p.minTrim = true
+	case inlineDisplay, inlineBlockDisplay:
+		p.minTrim = false
+	}
+	return nil
+}
+
+// unquotedAttr matches attribute values that don't need to be quoted
+// (https://html.spec.whatwg.org/multipage/syntax.html#unquoted). Ampersands are
+// also excluded to avoid creating character references.
+var unquotedAttr = regexp.MustCompile("^[^\t\n\f\r \"'=<>`&]+$")
+
+// minOpenTag returns n's opening tag with its attributes' values unquoted where possible.
+func (p *printer) minOpenTag(n *html.Node) string {
+	var b strings.Builder
+	b.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		b.WriteString(" " + a.Key)
+		val := a.Val
+		if a.Key == "class" {
+			val = strings.TrimSpace(whitespace.ReplaceAllString(val, " "))
+		}
+		switch {
+		case val == "":
+		case unquotedAttr.MatchString(val):
+			b.WriteString("=" + val)
+		default:
+			b.WriteString(`="` + strings.Replace(val, `"`, `&quot;`, -1) + `"`)
+		}
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// minText minifies text node n.
+func (p *printer) minText(n *html.Node) {
+	switch {
+	case p.inLiteral():
+		p.write(n.Data)
+	case p.inKeepSpace():
+		p.write(escapeText(n.Data))
+	default:
+		s := p.minCollapse(n, p.minTrim)
+		if s != "" {
+			p.write(s)
+			p.minTrim = strings.HasSuffix(s, " ")
+		}
+	}
+}
+
+// minCollapse returns text node n escaped and with its whitespace collapsed.
+// Leading whitespace is removed if trim is true, and trailing whitespace is removed
+// if it's followed by a block boundary.
+func (p *printer) minCollapse(n *html.Node, trim bool) string {
+	s := whitespace.ReplaceAllString(escapeText(n.Data), " ")
+	if trim {
+		s = strings.TrimLeft(s, " ")
+	}
+	if p.blockAfter(n) {
+		s = strings.TrimRight(s, " ")
+	}
+	return s
+}
+
+// blockAfter returns true if n is followed by a block boundary, so any trailing
+// whitespace in n isn't rendered. Comments, unrendered elements, and whitespace
+// are skipped.
+func (p *printer) blockAfter(n *html.Node) bool {
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		switch s.Type {
+		case html.ElementNode:
+			switch p.display(s) {
+			case blockDisplay, tableDisplay:
+				return true
+			case inlineDisplay, inlineBlockDisplay:
+				return false
+			}
+		case html.TextNode:
+			if !isSpaceText(s) {
+				return false
+			}
+		case html.CommentNode:
+		default:
+			return false
+		}
+	}
+	return !p.inlineContext(n.Parent)
+}
+
+// minOmitClose returns true if n's closing tag can be omitted from minified output because
+// it's in OmitCloseClass. Tags are only omitted if n is followed by another element with the
+// same tag or by the end of its parent, so that following content won't be moved into n.
+func (p *printer) minOmitClose(n *html.Node) bool {
+	if !p.omitCloseTags.has(n) {
+		return false
+	}
+	next := p.minNext(n)
+	return next == nil || (next.Type == html.ElementNode && next.Data == n.Data)
+}
+
+// minNext returns the first sibling after n that will be included in minified output,
+// or nil if there isn't one.
+func (p *printer) minNext(n *html.Node) *html.Node {
+	trim := p.display(n) != inlineDisplay
+	for s := n.NextSibling; s != nil; s = s.NextSibling {
+		switch {
+		case s.Type == html.CommentNode && !isConditionalComment(s):
+		case s.Type == html.TextNode && p.minCollapse(s, trim) == "":
+		default:
+			return s
+		}
+	}
+	return nil
+}
+
+// isConditionalComment returns true if n is a downlevel-hidden conditional comment
+// like "<!--[if IE]>...<![endif]-->", which is preserved by Minify.
+func isConditionalComment(n *html.Node) bool {
+	return n.Type == html.CommentNode &&
+		(strings.HasPrefix(n.Data, "[if ") || strings.HasSuffix(n.Data, "<![endif]"))
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestMinify(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <title>  My   page </title>
+    <!--[if IE]><link rel="stylesheet" href="ie.css"><![endif]-->
+    <script>
+      var x = 1;
+    </script>
+  </head>
+  <body>
+    <!-- comment -->
+    <div class="a   b" id="main" data-x="">
+      <p>Some <b>bold</b> text,   and <i> italic </i> text.</p>
+      <label>Name</label> <input type="text" value="a b"><br> next
+      <ul>
+        <li>One</li>
+        <li>Two</li>
+      </ul>
+      <ol><li>One</li> text</ol>
+      <pre>
+  keep   this
+</pre>
+      <a href="/x?a=1&amp;b=2" title='"q"'>link</a>
+    </div>
+  </body>
+</html>
+`
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("Parse failed:", err)
+	}
+	var b bytes.Buffer
+	if err := Minify(&b, root, nil); err != nil {
+		t.Fatal("Minify failed:", err)
+	}
+	checkOutput(t, b.String(), `<!DOCTYPE html><title>My page</title>`+
+		`<!--[if IE]><link rel="stylesheet" href="ie.css"><![endif]--><script>
+      var x = 1;
+    </script><div class="a b" id=main data-x>`+
+		`<p>Some <b>bold</b> text, and <i> italic </i> text.</p>`+
+		`<label>Name</label> <input type=text value="a b"><br> next`+
+		`<ul><li>One<li>Two</ul><ol><li>One</li>text</ol><pre>  keep   this
+</pre><a href="/x?a=1&b=2" title="&quot;q&quot;">link</a></div>`)
+
+	if err := Verify(root, b.Bytes(), &Options{SafeWhitespace: true}); err != nil {
+		t.Error("Verify failed:", err)
+	}
+}
+
+func TestMinifyDocument_Ignore(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<body>
+  <p>  Minified  </p>
+  <!-- htmlpretty-ignore -->
+  <div class="x">  keep this  </div>
+  <!-- htmlpretty-ignore-start -->
+  <p>  One  </p>
+  <p>  Two  </p>
+  <!-- htmlpretty-ignore-end -->
+  <p>  Minified  </p>
+</body>
+`
+	var b bytes.Buffer
+	if err := MinifyDocument(&b, []byte(doc), nil); err != nil {
+		t.Fatal("MinifyDocument failed:", err)
+	}
+	checkOutput(t, b.String(), `<!DOCTYPE html><p>Minified</p><!-- htmlpretty-ignore -->
+  <div class="x">  keep this  </div><!-- htmlpretty-ignore-start -->
+  <p>  One  </p>
+  <p>  Two  </p>
+  <!-- htmlpretty-ignore-end --><p>Minified`)
+
+	b.Reset()
+	if err := MinifyFragmentSource(&b, []byte("<p> a </p><!-- htmlpretty-ignore --><p> b </p>"), "", nil); err != nil {
+		t.Fatal("MinifyFragmentSource failed:", err)
+	}
+	checkOutput(t, b.String(), "<p>a</p><!-- htmlpretty-ignore --><p> b </p>")
+}
+
+func TestMinifyFragment(t *testing.T) {
+	nodes, err := ParseFragment(strings.NewReader("<td> a </td>\n<td>\n<b>b</b>\n</td>"), "tr")
+	if err != nil {
+		t.Fatal("ParseFragment failed:", err)
+	}
+	var b bytes.Buffer
+	if err := MinifyFragment(&b, nodes, nil); err != nil {
+		t.Fatal("MinifyFragment failed:", err)
+	}
+	checkOutput(t, b.String(), "<td>a<td><b>b</b>")
+}
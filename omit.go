@@ -0,0 +1,150 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// CloseTagMode describes which tags are omitted from the output.
+type CloseTagMode string
+
+const (
+	// DefaultCloseTags omits the closing tags of elements in OmitCloseClass.
+	DefaultCloseTags CloseTagMode = ""
+	// NamedDefaultCloseTags is equivalent to DefaultCloseTags. It allows the default mode
+	// to be requested explicitly, e.g. to override a config file's mode via a flag.
+	NamedDefaultCloseTags CloseTagMode = "default"
+	// AlwaysCloseTags writes closing tags for all non-void elements, including
+	// elements in OmitCloseClass, whose contents are then nested like those of other
+	// block elements. plaintext elements still lack closing tags, since their contents
+	// extend to the end of the document.
+	AlwaysCloseTags CloseTagMode = "always"
+	// OmitOptionalTags omits opening and closing tags where permitted by
+	// https://html.spec.whatwg.org/multipage/syntax.html#optional-tags, e.g. the
+	// closing tags of p elements that are followed by other p elements.
+	// OmitCloseClass is ignored.
+	OmitOptionalTags CloseTagMode = "omitOptional"
+)
+
+// Elements whose closing tags can be omitted when followed by one of the listed elements.
+var optionalCloseFollowers = map[string]tagSet{
+	"li":       newTagSet([]string{"li"}),
+	"dt":       newTagSet([]string{"dt", "dd"}),
+	"dd":       newTagSet([]string{"dd", "dt"}),
+	"p":        newTagSet(strings.Fields("address article aside blockquote details dialog div dl fieldset figcaption figure footer form h1 h2 h3 h4 h5 h6 header hgroup hr main menu nav ol p pre search section table ul")),
+	"rt":       newTagSet([]string{"rt", "rp"}),
+	"rp":       newTagSet([]string{"rt", "rp"}),
+	"optgroup": newTagSet([]string{"optgroup", "hr"}),
+	"option":   newTagSet([]string{"option", "optgroup", "hr"}),
+	"thead":    newTagSet([]string{"tbody", "tfoot"}),
+	"tbody":    newTagSet([]string{"tbody", "tfoot"}),
+	"tr":       newTagSet([]string{"tr"}),
+	"td":       newTagSet([]string{"td", "th"}),
+	"th":       newTagSet([]string{"td", "th"}),
+}
+
+// Elements whose closing tags can be omitted at the end of their parents.
+var optionalCloseAtEnd = newTagSet(strings.Fields("li dd rt rp optgroup option tbody tfoot tr td th"))
+
+// Elements whose closing tags can be omitted unless they're followed by a comment.
+var optionalCloseUnlessComment = newTagSet(strings.Fields("html head body"))
+
+// Elements whose closing tags can be omitted unless they're followed by whitespace or a comment.
+// The pretty-printer always writes whitespace after them, so they're only omitted when minifying.
+var optionalCloseUnlessSpace = newTagSet(strings.Fields("caption colgroup"))
+
+// Parents that prevent a p element's closing tag from being omitted at the end of its contents.
+var pCloseParents = newTagSet(strings.Fields("a audio del ins map noscript video"))
+
+// Elements that prevent body's opening tag from being omitted if they appear first in it.
+var bodyOpenFirst = newTagSet(strings.Fields("meta noscript link script style template"))
+
+// omitOpen returns true if n's opening tag should be omitted.
+func (p *printer) omitOpen(n *html.Node) bool {
+	if p.opts.CloseTags == AlwaysCloseTags || (p.opts.CloseTags != OmitOptionalTags && !p.minify) {
+		return false
+	}
+	return p.optionalOpen(n)
+}
+
+// omitClose returns true if n's closing tag should be omitted.
+func (p *printer) omitClose(n *html.Node) bool {
+	switch p.opts.CloseTags {
+	case AlwaysCloseTags:
+		return false
+	case OmitOptionalTags:
+		return p.optionalClose(n) && (p.minify || !optionalCloseUnlessSpace.has(n))
+	default:
+		if p.minify {
+			return p.optionalClose(n) || p.minOmitClose(n)
+		}
+		return p.omitCloseTags.has(n)
+	}
+}
+
+// optionalOpen returns true if the spec permits element n's opening tag to be omitted.
+func (p *printer) optionalOpen(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Namespace != "" || len(n.Attr) > 0 {
+		return false
+	}
+	first := p.significant(n.FirstChild)
+	switch n.Data {
+	case "html":
+		return first == nil || first.Type != html.CommentNode
+	case "head":
+		return first == nil || first.Type == html.ElementNode
+	case "body":
+		return first == nil || (first.Type != html.CommentNode && !bodyOpenFirst.has(first))
+	}
+	return false
+}
+
+// optionalClose returns true if the spec permits element n's closing tag to be omitted.
+func (p *printer) optionalClose(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Namespace != "" {
+		return false
+	}
+	if optionalCloseUnlessSpace.has(n) {
+		// Whitespace is significant here, so only skip comments that won't be printed.
+		next := n.NextSibling
+		for next != nil && next.Type == html.CommentNode && p.significant(next) != next {
+			next = next.NextSibling
+		}
+		return next == nil || (next.Type != html.CommentNode && !isSpaceText(next))
+	}
+	next := p.significant(n.NextSibling)
+	if optionalCloseUnlessComment.has(n) {
+		return next == nil || next.Type != html.CommentNode
+	}
+	if next == nil {
+		if n.Data == "p" {
+			// The parent needs to be known to be an element that p can be implicitly closed by.
+			parent := n.Parent
+			return parent != nil && parent.Type == html.ElementNode && !pCloseParents.has(parent) &&
+				!strings.Contains(parent.Data, "-")
+		}
+		return optionalCloseAtEnd.has(n)
+	}
+	followers, ok := optionalCloseFollowers[n.Data]
+	return ok && next.Namespace == "" && followers.has(next)
+}
+
+// significant returns the first of n and its following siblings that will affect
+// how the output is parsed, skipping whitespace and comments that won't be printed.
+// Returns nil if there is no such node.
+func (p *printer) significant(n *html.Node) *html.Node {
+	for ; n != nil; n = n.NextSibling {
+		switch {
+		case n.Type == html.TextNode && isSpaceText(n):
+		case n.Type == html.CommentNode && (p.opts.StripComments ||
+			(p.minify && !isConditionalComment(n) && !isIgnoreDirective(n))):
+		default:
+			return n
+		}
+	}
+	return nil
+}
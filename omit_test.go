@@ -0,0 +1,269 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+const closeTagsDoc = `<!DOCTYPE html>
+<html>
+<head><title>Title</title></head>
+<body>
+<p>One</p><p>Two</p> <div>Three</div>
+<dl><dt>A</dt><dd>B</dd></dl>
+<table><caption>Cap</caption><colgroup><col></colgroup><tr><th>H</th><td>1</td></tr></table>
+<ul><li>One</li><li>Two</li></ul>
+<p>Five</p><!-- comment -->
+</body>
+</html>
+`
+
+func TestPrint_CloseTags(t *testing.T) {
+	for _, tc := range []struct {
+		mode CloseTagMode
+		exp  string
+	}{
+		{DefaultCloseTags, `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Title</title>
+  </head>
+  <body>
+    <p>One</p>
+    <p>Two</p>
+    <div>Three</div>
+    <dl>
+      <dt>A</dt>
+      <dd>B</dd>
+    </dl>
+    <table>
+      <caption>Cap</caption>
+      <colgroup>
+        <col>
+      </colgroup>
+      <tbody>
+        <tr>
+          <th>H</th>
+          <td>1</td>
+        </tr>
+      </tbody>
+    </table>
+    <ul>
+      <li>One
+      <li>Two
+    </ul>
+    <p>Five</p>
+    <!-- comment -->
+  </body>
+</html>
+`},
+		{OmitOptionalTags, `<!DOCTYPE html>
+<title>Title</title>
+<p>One
+<p>Two
+<div>Three</div>
+<dl>
+  <dt>A
+  <dd>B
+</dl>
+<table>
+  <caption>Cap</caption>
+  <colgroup>
+    <col>
+  </colgroup>
+  <tbody>
+    <tr>
+      <th>H
+      <td>1
+</table>
+<ul>
+  <li>One
+  <li>Two
+</ul>
+<p>Five</p>
+<!-- comment -->
+`},
+	} {
+		opts := &Options{Indent: "  ", CloseTags: tc.mode}
+		checkPrintOptions(t, closeTagsDoc, opts, tc.exp)
+		if tc.mode == DefaultCloseTags {
+			checkPrintOptions(t, closeTagsDoc, &Options{Indent: "  ", CloseTags: NamedDefaultCloseTags}, tc.exp)
+		}
+
+		root, err := html.Parse(strings.NewReader(closeTagsDoc))
+		if err != nil {
+			t.Fatal("Parse failed: ", err)
+		}
+		var b bytes.Buffer
+		if err := PrintWithOptions(&b, root, opts); err != nil {
+			t.Fatal("Print failed: ", err)
+		}
+		if err := Verify(root, b.Bytes(), opts); err != nil {
+			t.Errorf("Verify failed for %q: %v", tc.mode, err)
+		}
+	}
+}
+
+func TestPrint_CloseTagsInvalid(t *testing.T) {
+	root, err := html.Parse(strings.NewReader("<p>Text</p>"))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	if err := PrintWithOptions(&bytes.Buffer{}, root, &Options{CloseTags: "bogus"}); err == nil {
+		t.Error("PrintWithOptions with bogus close tag mode unexpectedly succeeded")
+	} else if !strings.Contains(err.Error(), `"omitOptional"`) {
+		t.Errorf("PrintWithOptions with bogus close tag mode returned %q; want valid modes listed", err)
+	}
+}
+
+func TestOptionalTags(t *testing.T) {
+	for _, tc := range []struct {
+		frag, context string
+		open, close   []bool // expected results for top-level nodes
+	}{
+		{"<li>a</li><li>b</li>", "ul", []bool{false, false}, []bool{true, true}},
+		{"<li>a</li>text", "ul", []bool{false, false}, []bool{false, false}},
+		{"<dt>a</dt><dd>b</dd><dt>c</dt>", "dl", []bool{false, false, false}, []bool{true, true, false}},
+		{"<p>a</p> <ul></ul><p>b</p><span></span><p>c</p>", "div",
+			[]bool{false, false, false, false, false, false}, []bool{true, false, false, false, false, true}},
+		{"<p>a</p>", "a", []bool{false}, []bool{false}},
+		{"<p>a</p>", "my-element", []bool{false}, []bool{false}},
+		{"<option>a</option><optgroup></optgroup><hr>", "select",
+			[]bool{false, false, false}, []bool{true, true, false}},
+		{"<thead></thead><tfoot></tfoot>", "table", []bool{false, false}, []bool{true, true}},
+		{"<tbody></tbody><tbody></tbody>", "table", []bool{false, false}, []bool{true, true}},
+		{"<rt>a</rt><rp>b</rp>", "ruby", []bool{false, false}, []bool{true, true}},
+		{"<caption></caption><colgroup></colgroup><tbody></tbody>", "table",
+			[]bool{false, false, false}, []bool{true, true, true}},
+		{"<caption></caption> <colgroup></colgroup><!-- c --><tbody></tbody>", "table",
+			[]bool{false, false, false, false, false}, []bool{false, false, false, false, true}},
+	} {
+		nodes, err := ParseFragment(strings.NewReader(tc.frag), tc.context)
+		if err != nil {
+			t.Fatalf("ParseFragment(%q, %q) failed: %v", tc.frag, tc.context, err)
+		}
+		parent := &html.Node{Type: html.ElementNode, Data: tc.context}
+		for _, n := range nodes {
+			parent.AppendChild(n)
+		}
+		p, _ := newPrinter(&bytes.Buffer{}, nil)
+		for i, n := range nodes {
+			if got := p.optionalOpen(n); got != tc.open[i] {
+				t.Errorf("optionalOpen(%q) in %q = %v; want %v", n.Data, tc.frag, got, tc.open[i])
+			}
+			if got := p.optionalClose(n); got != tc.close[i] {
+				t.Errorf("optionalClose(%q) in %q = %v; want %v", n.Data, tc.frag, got, tc.close[i])
+			}
+		}
+	}
+}
+
+func TestOptionalTags_Document(t *testing.T) {
+	for _, tc := range []struct {
+		doc                 string
+		html, head, body    bool // expected optionalOpen results
+		htmlC, headC, bodyC bool // expected optionalClose results
+	}{
+		{"<title>a</title><p>b", true, true, true, true, true, true},
+		{"<html lang=en><head></head><body class=x>", false, true, false, true, true, true},
+		{"<!--a--><html><head><!--b--></head><!--c--><body><!--d--></body></html><!--e-->",
+			true, false, false, false, false, true},
+		{"<body><script></script>", true, true, false, true, true, true},
+	} {
+		root, err := html.Parse(strings.NewReader(tc.doc))
+		if err != nil {
+			t.Fatal("Parse failed: ", err)
+		}
+		htmlNode := root.LastChild
+		for htmlNode.Type != html.ElementNode {
+			htmlNode = htmlNode.PrevSibling
+		}
+		head := htmlNode.FirstChild
+		body := head.NextSibling
+		for body.Type != html.ElementNode {
+			body = body.NextSibling
+		}
+		p, _ := newPrinter(&bytes.Buffer{}, nil)
+		for _, c := range []struct {
+			n         *html.Node
+			open, cls bool
+		}{{htmlNode, tc.html, tc.htmlC}, {head, tc.head, tc.headC}, {body, tc.body, tc.bodyC}} {
+			if got := p.optionalOpen(c.n); got != c.open {
+				t.Errorf("optionalOpen(%q) in %q = %v; want %v", c.n.Data, tc.doc, got, c.open)
+			}
+			if got := p.optionalClose(c.n); got != c.cls {
+				t.Errorf("optionalClose(%q) in %q = %v; want %v", c.n.Data, tc.doc, got, c.cls)
+			}
+		}
+	}
+}
+
+func TestMinify_CaptionColgroup(t *testing.T) {
+	root, err := html.Parse(strings.NewReader("<table><caption>a</caption><colgroup><col></colgroup>" +
+		"<tr><td>b</table><table><caption>c</caption> <colgroup><col></colgroup><!--[if IE]>x<![endif]--></table>"))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	var b bytes.Buffer
+	if err := Minify(&b, root, nil); err != nil {
+		t.Fatal("Minify failed: ", err)
+	}
+	checkOutput(t, b.String(), "<table><caption>a<colgroup><col><tbody><tr><td>b</table>"+
+		"<table><caption>c</caption><colgroup><col></colgroup><!--[if IE]>x<![endif]--></table>")
+}
+
+func TestPrint_ListAlwaysClose(t *testing.T) {
+	checkPrintOptions(t, `<!DOCTYPE html>
+<html>
+  <body>
+    <ol><li>First<li><p>Second</p><p>Third</p></ol>
+	<ul><li>First<li>Second: this one is a bit longer and needs multiple lines<li>x<ul><li>Nested</ul></ul>
+  </body>
+</html>
+`, &Options{Indent: "  ", Wrap: 50, CloseTags: AlwaysCloseTags}, `<!DOCTYPE html>
+<html>
+  <head></head>
+  <body>
+    <ol>
+      <li>First</li>
+      <li>
+        <p>Second</p>
+        <p>Third</p>
+      </li>
+    </ol>
+    <ul>
+      <li>First</li>
+      <li>
+        Second: this one is a bit longer and needs
+        multiple lines
+      </li>
+      <li>
+        x
+        <ul>
+          <li>Nested</li>
+        </ul>
+      </li>
+    </ul>
+  </body>
+</html>
+`)
+}
+
+func TestMinify_AlwaysClose(t *testing.T) {
+	root, err := html.Parse(strings.NewReader("<ul><li>a<li>b</ul><p>c<p>d"))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	var b bytes.Buffer
+	if err := Minify(&b, root, &Options{CloseTags: AlwaysCloseTags}); err != nil {
+		t.Fatal("Minify failed: ", err)
+	}
+	checkOutput(t, b.String(), "<html><head></head><body><ul><li>a</li><li>b</li></ul>"+
+		"<p>c</p><p>d</p></body></html>")
+}
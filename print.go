@@ -11,17 +11,79 @@ import (
 	"strings"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
+// Options configures how documents are printed.
+// The zero value is valid and prints without indentation or wrapping.
+type Options struct {
+	// Indent is used for a single level of indenting.
+	Indent string `json:"indent"`
+	// Wrap is the line width in columns at which lines will be wrapped where possible.
+	// East Asian wide characters occupy two columns and combining marks occupy none.
+	// Lines are not wrapped if Wrap is zero or negative.
+	Wrap int `json:"wrap"`
+	// TabWidth is the number of columns between tab stops. It applies to all tabs written to
+	// the output, including ones in Indent, text, and attribute values, when measuring lines
+	// for Wrap. If zero (the default) or negative, tabs are counted as a single column.
+	TabWidth int `json:"tabWidth,omitempty"`
+
+	// StripComments removes comments from the output.
+	StripComments bool `json:"stripComments,omitempty"`
+	// WrapComments reflows the text of comments like other text.
+	// By default, comments are printed verbatim.
+	WrapComments bool `json:"wrapComments,omitempty"`
+
+	// SafeWhitespace decides where whitespace can be added or removed using each element's
+	// default CSS display value (block, inline, inline-block, table part, or none) from the
+	// HTML specification's user agent stylesheet instead of just InlineClass, so that visible
+	// whitespace is never inserted between inline-level elements like <label> and <input>.
+	// Unknown elements are inline. Changes to InlineClass in Tags are still honored.
+	// <select> and <datalist> are also added to ListClass unless Tags removes them.
+	SafeWhitespace bool `json:"safeWhitespace,omitempty"`
+	// CSSDisplay refines each element's display value using display properties set by
+	// <style> elements and style attributes in the document, e.g. "nav a { display: block }".
+	// Only type, class, and ID selectors and descendant combinators are supported, and
+	// at-rules like @media are ignored. This is most useful with SafeWhitespace.
+	CSSDisplay bool `json:"cssDisplay,omitempty"`
+
+	// ReindentScripts reindents the contents of multi-line JavaScript <script> elements to
+	// match the elements' nesting levels. Scripts containing template literals are left
+	// unchanged, since the literals' contents may include significant whitespace.
+	ReindentScripts bool `json:"reindentScripts,omitempty"`
+	// FormatCSS formats the contents of <style> elements with one declaration per line,
+	// indented to match the elements' nesting levels, and normalizes the spacing of
+	// declarations in style attributes, e.g. "color: red; margin: 0".
+	// CSS that can't be parsed is printed unchanged.
+	FormatCSS bool `json:"formatCSS,omitempty"`
+	// FormatJSON reindents the contents of <script> elements with type "application/json" or
+	// "application/ld+json" to match the elements' nesting levels. Invalid JSON is printed unchanged.
+	FormatJSON bool `json:"formatJSON,omitempty"`
+	// Formatters maps from element and MIME type to formatters for the contents of elements
+	// in LiteralClass, e.g. {"script", "text/x-template"}. These take precedence over
+	// ReindentScripts, FormatCSS, and FormatJSON.
+	Formatters map[EmbeddedType]EmbeddedFormatter `json:"-"`
+
+	// CloseTags controls which optional tags are omitted. By default (or if it is
+	// NamedDefaultCloseTags), only the closing tags of elements in OmitCloseClass are omitted.
+	CloseTags CloseTagMode `json:"closeTags,omitempty"`
+	// Tags contains changes to the default sets of elements in each TagClass.
+	Tags map[TagClass]TagChanges `json:"tags,omitempty"`
+}
+
 // Print pretty-prints the supplied HTML document to w.
 // The supplied indent string is used for a single level of indenting.
-// If wrap is positive, lines will be wrapped at that many bytes where possible.
+// If wrap is positive, lines will be wrapped at that many columns where possible.
 func Print(w io.Writer, root *html.Node, indent string, wrap int) error {
-	p := printer{
-		w:         w,
-		indentStr: indent,
-		wrapWidth: wrap,
-		lineStart: true,
+	return PrintWithOptions(w, root, &Options{Indent: indent, Wrap: wrap})
+}
+
+// PrintWithOptions pretty-prints the supplied HTML document to w using opts.
+// If opts is nil, the zero value of Options is used.
+func PrintWithOptions(w io.Writer, root *html.Node, opts *Options) error {
+	p, err := newPrinter(w, opts)
+	if err != nil {
+		return err
 	}
 	if err := p.doc(root); err != nil {
 		return err
@@ -29,6 +91,56 @@ func Print(w io.Writer, root *html.Node, indent string, wrap int) error {
 	return p.werr
 }
 
+// PrintNode pretty-prints n and its descendants to w using opts.
+// Unlike PrintWithOptions, n may be any type of node.
+// If opts is nil, the zero value of Options is used.
+func PrintNode(w io.Writer, n *html.Node, opts *Options) error {
+	p, err := newPrinter(w, opts)
+	if err != nil {
+		return err
+	}
+	if n.Type == html.DocumentNode {
+		err = p.doc(n)
+	} else {
+		p.loadStyles([]*html.Node{n})
+		if err = p.node(n); err == nil {
+			p.endl()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// PrintFragment pretty-prints the supplied nodes (e.g. as returned by ParseFragment) to w
+// using opts. Unlike PrintWithOptions, no document structure is required or added.
+// If opts is nil, the zero value of Options is used.
+func PrintFragment(w io.Writer, nodes []*html.Node, opts *Options) error {
+	p, err := newPrinter(w, opts)
+	if err != nil {
+		return err
+	}
+	if err := p.fragment(nodes); err != nil {
+		return err
+	}
+	return p.werr
+}
+
+// ParseFragment parses an HTML fragment from r as if it were the contents of an element
+// with the supplied context tag name, e.g. "body" or "tr". If context is empty, "body" is used.
+// Unlike html.Parse, no html, head, or body elements are synthesized.
+func ParseFragment(r io.Reader, context string) ([]*html.Node, error) {
+	if context == "" {
+		context = "body"
+	}
+	return html.ParseFragment(r, &html.Node{
+		Type:     html.ElementNode,
+		Data:     context,
+		DataAtom: atom.Lookup([]byte(context)),
+	})
+}
+
 // tagSet holds a set of HTML tag names.
 type tagSet map[string]struct{}
 
@@ -50,9 +162,35 @@ func (ts tagSet) has(n *html.Node) bool {
 	return ok
 }
 
+// TagClass identifies a set of elements that are formatted in the same way.
+type TagClass string
+
+const (
+	// VoidClass contains void elements, which have no contents or closing tags.
+	VoidClass TagClass = "void"
+	// InlineClass contains elements that appear inline without newlines around them.
+	InlineClass TagClass = "inline"
+	// ListClass contains elements whose children are indented and displayed on their own lines.
+	ListClass TagClass = "list"
+	// OmitCloseClass contains non-void elements whose closing tags are omitted.
+	OmitCloseClass TagClass = "omitClose"
+	// LiteralClass contains elements whose contents are printed unchanged.
+	LiteralClass TagClass = "literal"
+	// KeepSpaceClass contains elements whose contents retain their whitespace but are escaped.
+	KeepSpaceClass TagClass = "keepSpace"
+)
+
+// TagChanges describes changes to the default set of elements in a TagClass.
+type TagChanges struct {
+	// Add lists tag names to add to the set.
+	Add []string `json:"add,omitempty"`
+	// Remove lists tag names to remove from the set. It is applied after Add.
+	Remove []string `json:"remove,omitempty"`
+}
+
 // Void elements per https://html.spec.whatwg.org/multipage/syntax.html.
 // https://www.w3.org/TR/2011/WD-html-markup-20110405/syntax.html#syntax-elements lists a few more.
-var voidTags = newTagSet(strings.Fields("area base br col embed hr img input link meta param source track wbr"))
+var defaultVoidTags = newTagSet(strings.Fields("area base br col embed hr img input link meta param source track wbr"))
 
 // Elements that appear inline.
 // No newline is added before the element or after it.
@@ -60,37 +198,158 @@ var voidTags = newTagSet(strings.Fields("area base br col embed hr img input lin
 // the opening tag, and the last child appears immediately before the closing tag.
 // Spaces in text nodes adjacent to these tags are preserved.
 // This is based on the list at https://developer.mozilla.org/en-US/docs/Web/HTML/Inline_elements.
-var inlineTags = newTagSet(strings.Fields("a abbr acronym amp-img b big cite code data def del dfn em " +
+var defaultInlineTags = newTagSet(strings.Fields("a abbr acronym amp-img b big cite code data def del dfn em " +
 	"i img ins kbd mark picture q s small span source strong sub sup svg time tt u wbr"))
 
 // Elements whose children should be indented and displayed on their own lines.
-// This overrides inlineTags's behavior, and it primarily exists to improve the
+// This overrides the inline class's behavior, and it primarily exists to improve the
 // formatting of picture elements containing source and img elements, and of
 // nested amp-img elements.
-var listTags = newTagSet(strings.Fields("amp-img ol picture svg ul"))
+var defaultListTags = newTagSet(strings.Fields("amp-img ol picture svg ul"))
+
+// Elements that are added to the list class when Options.SafeWhitespace is set,
+// so their options stay on separate lines when they're treated as inline-block.
+var safeWhitespaceListTags = []string{"datalist", "select"}
 
 // Non-void elements whose closing tags are omitted.
 // Similar to inline tags, these tags also don't nest their contents.
 // A newline is printed at the point where the closing tag would have appeared, though.
-var omitCloseTags = newTagSet(strings.Fields("li"))
+var defaultOmitCloseTags = newTagSet(strings.Fields("li"))
 
 // Elements whose contents should be preserved unchanged (i.e. no whitespace changes or escaping).
-var literalTags = newTagSet(strings.Fields("noscript script style"))
+// These are the raw text elements from https://html.spec.whatwg.org/multipage/parsing.html,
+// whose contents aren't unescaped by the parser.
+var defaultLiteralTags = newTagSet(strings.Fields("iframe noembed noframes noscript plaintext script style xmp"))
 
 // Elements whose contents should retain their original whitespace but still be escaped.
-var keepSpaceTags = newTagSet(strings.Fields("pre"))
+// title is deliberately absent: its text is also escapable, but its whitespace is collapsed
+// (https://html.spec.whatwg.org/multipage/dom.html#document.title).
+var defaultKeepSpaceTags = newTagSet(strings.Fields("listing pre textarea"))
+
+// Elements whose opening tags are followed by a newline that's dropped by the parser
+// (https://html.spec.whatwg.org/multipage/syntax.html#element-restrictions).
+var leadingNewlineTags = newTagSet(strings.Fields("listing pre textarea"))
+
+// defaultTags maps from each TagClass to its default set of elements.
+var defaultTags = map[TagClass]tagSet{
+	VoidClass:      defaultVoidTags,
+	InlineClass:    defaultInlineTags,
+	ListClass:      defaultListTags,
+	OmitCloseClass: defaultOmitCloseTags,
+	LiteralClass:   defaultLiteralTags,
+	KeepSpaceClass: defaultKeepSpaceTags,
+}
 
 type printer struct {
-	w         io.Writer
-	werr      error // first error seen while writing to w
-	indentStr string
-	wrapWidth int
+	w    io.Writer
+	werr error // first error seen while writing to w
+	opts Options
+	src  *source // original text of the document, if available
+
+	voidTags      tagSet
+	inlineTags    tagSet
+	listTags      tagSet
+	omitCloseTags tagSet
+	literalTags   tagSet
+	keepSpaceTags tagSet
+	displays      map[string]display                 // display values if opts.SafeWhitespace is set
+	styles        *stylesheet                        // display values from CSS if opts.CSSDisplay is set
+	formatters    map[EmbeddedType]EmbeddedFormatter // formatters for literal elements' contents
 
 	level          int  // current indentation level
 	literalDepth   int  // number of literalTags elements that we're nested in
 	keepSpaceDepth int  // number of keepSpaceTags elements that we're nested in
 	lineStart      bool // true if we're at the start of a line
 	lineWidth      int  // width of the current line
+	ended          bool // true after printing a plaintext element, which consumes the rest of the input
+	minify         bool // true if minifying rather than pretty-printing
+	minTrim        bool // when minifying, true if leading whitespace in the next text wouldn't be rendered
+}
+
+func newPrinter(w io.Writer, opts *Options) (*printer, error) {
+	p := printer{w: w, lineStart: true}
+	if opts != nil {
+		p.opts = *opts
+	}
+
+	for class := range p.opts.Tags {
+		if _, ok := defaultTags[class]; !ok {
+			return nil, fmt.Errorf("unknown tag class %q", class)
+		}
+	}
+	switch p.opts.CloseTags {
+	case DefaultCloseTags, NamedDefaultCloseTags, AlwaysCloseTags, OmitOptionalTags:
+	default:
+		return nil, fmt.Errorf("unknown close tag mode %q (want %q, %q, or %q)",
+			p.opts.CloseTags, NamedDefaultCloseTags, AlwaysCloseTags, OmitOptionalTags)
+	}
+	for _, ts := range []struct {
+		dst   *tagSet
+		class TagClass
+	}{
+		{&p.voidTags, VoidClass},
+		{&p.inlineTags, InlineClass},
+		{&p.listTags, ListClass},
+		{&p.omitCloseTags, OmitCloseClass},
+		{&p.literalTags, LiteralClass},
+		{&p.keepSpaceTags, KeepSpaceClass},
+	} {
+		*ts.dst = make(tagSet)
+		for t := range defaultTags[ts.class] {
+			(*ts.dst)[t] = struct{}{}
+		}
+		changes := p.opts.Tags[ts.class]
+		for _, t := range changes.Add {
+			(*ts.dst)[strings.ToLower(t)] = struct{}{}
+		}
+		for _, t := range changes.Remove {
+			delete(*ts.dst, strings.ToLower(t))
+		}
+	}
+
+	p.initFormatters()
+
+	if p.opts.SafeWhitespace {
+		p.displays = make(map[string]display, len(defaultDisplays))
+		for t, d := range defaultDisplays {
+			p.displays[t] = d
+		}
+		changes := p.opts.Tags[InlineClass]
+		for _, t := range changes.Add {
+			t = strings.ToLower(t)
+			if d, ok := p.displays[t]; ok && d != inlineBlockDisplay {
+				p.displays[t] = inlineDisplay
+			}
+		}
+		for _, t := range changes.Remove {
+			p.displays[strings.ToLower(t)] = blockDisplay
+		}
+		for _, t := range safeWhitespaceListTags {
+			p.listTags[t] = struct{}{}
+		}
+		for _, t := range p.opts.Tags[ListClass].Remove {
+			delete(p.listTags, strings.ToLower(t))
+		}
+	}
+
+	for t := range p.voidTags {
+		if _, ok := p.literalTags[t]; ok {
+			return nil, fmt.Errorf("<%s> is both literal and void", t)
+		}
+		if _, ok := p.keepSpaceTags[t]; ok {
+			return nil, fmt.Errorf("<%s> is both keep-space and void", t)
+		}
+	}
+
+	return &p, nil
+}
+
+// loadStyles reads display values from <style> elements in the trees containing nodes
+// if p.opts.CSSDisplay is set.
+func (p *printer) loadStyles(nodes []*html.Node) {
+	if p.opts.CSSDisplay && p.styles == nil {
+		p.styles = newStylesheet(nodes)
+	}
 }
 
 func (p *printer) inLiteral() bool {
@@ -106,19 +365,96 @@ func (p *printer) doc(n *html.Node) error {
 	if n.Type != html.DocumentNode {
 		return fmt.Errorf("root node has non-document type %v", n.Type)
 	}
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		switch c.Type {
-		case html.DoctypeNode:
-			p.write("<!DOCTYPE " + c.Data + ">")
-			p.endl()
-		case html.ElementNode:
-			if err := p.element(c); err != nil {
+	p.loadStyles([]*html.Node{n})
+	if err := p.siblings(n.FirstChild, n.LastChild, false); err != nil {
+		return err
+	}
+	p.endl()
+	return nil
+}
+
+// fragment handles the supplied nodes, which need not be part of a document.
+func (p *printer) fragment(nodes []*html.Node) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	p.loadStyles(nodes)
+
+	if detach, ok := attach(nodes); ok {
+		defer detach()
+		if err := p.siblings(nodes[0], nodes[len(nodes)-1], false); err != nil {
+			return err
+		}
+	} else {
+		for _, n := range nodes {
+			if err := p.node(n); err != nil {
 				return err
 			}
-		default:
-			return fmt.Errorf("unhandled doc child %q with type %v", c.Data, c.Type)
 		}
 	}
+	p.endl()
+	return nil
+}
+
+// attach gives nodes a temporary parent if they're all detached, as is the case for nodes
+// returned by html.ParseFragment. The printer looks at adjacent nodes to decide how to handle
+// whitespace. If true is returned, detach must be called to restore the nodes afterward.
+func attach(nodes []*html.Node) (detach func(), ok bool) {
+	for _, n := range nodes {
+		if n.Parent != nil || n.PrevSibling != nil || n.NextSibling != nil {
+			return nil, false
+		}
+	}
+	parent := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		parent.AppendChild(n)
+	}
+	return func() {
+		for _, n := range nodes {
+			parent.RemoveChild(n)
+		}
+	}, true
+}
+
+// siblings handles the nodes from first to last (inclusive), which must be siblings.
+// If list is true, a newline is written after each element.
+func (p *printer) siblings(first, last *html.Node, list bool) error {
+	for c := first; c != nil; c = c.NextSibling {
+		// If c is an ignore directive, skip the nodes that were written verbatim.
+		if end := p.ignore(c); end != nil {
+			c = end
+		} else if err := p.node(c); err != nil {
+			return err
+		}
+		if list && c.Type == html.ElementNode {
+			p.endl()
+		}
+		if c == last {
+			break
+		}
+	}
+	return nil
+}
+
+// node handles the supplied node by calling the appropriate method for its type.
+func (p *printer) node(n *html.Node) error {
+	switch n.Type {
+	case html.DoctypeNode:
+		p.endl()
+		p.write("<!DOCTYPE " + n.Data + ">")
+		p.endl()
+	case html.ElementNode:
+		return p.element(n)
+	case html.TextNode:
+		return p.text(n)
+	case html.CommentNode:
+		p.comment(n)
+	case html.RawNode:
+		// Raw nodes are written verbatim by html.Render, so do the same.
+		p.write(n.Data)
+	default:
+		return fmt.Errorf("unexpected node %q of type %d", n.Data, n.Type)
+	}
 	return nil
 }
 
@@ -130,71 +466,78 @@ func (p *printer) element(n *html.Node) error {
 	}
 
 	// Print the opening tag first.
-	inline := inlineTags.has(n)
-	if forceInline := p.openTag(n); forceInline {
+	level := p.level
+	inline := p.isInline(n)
+	omitOpen := p.omitOpen(n)
+	if omitOpen {
+		inline = false
+	} else if forceInline := p.openTag(n); forceInline {
 		inline = true
 	}
 
 	// Preserve the formatting of the things that we'll print next if needed.
-	literal := literalTags.has(n)
+	literal := p.literalTags.has(n)
 	if literal {
 		p.literalDepth++
 	}
-	keepSpace := keepSpaceTags.has(n)
+	keepSpace := p.keepSpaceTags.has(n)
 	if keepSpace {
 		p.keepSpaceDepth++
 	}
 
-	if voidTags.has(n) {
+	if p.voidTags.has(n) {
 		if literal || keepSpace {
 			panic(fmt.Sprintf("<%s> is both literal/keep-space and void", n.Data))
 		}
 		return nil
 	}
 
+	// If the contents start with a newline, write an extra one in its place
+	// so the one in the contents won't be dropped when the output is parsed.
+	if leadingNewlineTags.has(n) && n.FirstChild != nil && n.FirstChild.Type == html.TextNode &&
+		strings.HasPrefix(n.FirstChild.Data, "\n") {
+		p.write("\n")
+	}
+
 	hasChildren := n.FirstChild != nil
-	listChildren := listTags.has(n)
-	omitClose := omitCloseTags.has(n)
+	listChildren := p.listTags.has(n)
+	omitClose := p.omitClose(n)
 
 	if hasChildren {
 		// Indent if needed before printing the children.
+		// Contents start on the same line as the opening tag if the closing tag is omitted,
+		// and aren't indented if the opening tag is omitted.
 		if !inline || listChildren {
 			if !omitClose {
 				p.endl()
 			}
-			p.level++
-		}
-
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			switch c.Type {
-			case html.ElementNode:
-				if err := p.element(c); err != nil {
-					return err
-				}
-				if listChildren {
-					p.endl()
-				}
-			case html.TextNode:
-				if err := p.text(c); err != nil {
-					return err
-				}
-			case html.CommentNode:
-				// TODO: Don't strip comments, maybe?
-				continue
-			default:
-				return fmt.Errorf("unexpected node %q of type %d", c.Data, c.Type)
+			if !omitOpen {
+				p.level++
 			}
 		}
+
+		if content, ok := p.embedded(n, level); ok {
+			p.write(content)
+		} else if err := p.siblings(n.FirstChild, n.LastChild, listChildren); err != nil {
+			return err
+		}
 		if !inline || listChildren {
-			p.level--
+			if !omitOpen {
+				p.level--
+			}
 			p.endl()
 		}
 	}
 
+	// Nothing can be written after plaintext since it would be parsed as text.
+	if n.Data == "plaintext" {
+		p.ended = true
+	}
+
 	// Avoid wrapping the closing tag.
 	if !omitClose {
 		p.maybeIndent()
-		p.write(closeTag(n))
+		p.write(p.closeTag(n))
 	}
 	if literal {
 		p.literalDepth--
@@ -234,7 +577,10 @@ func (p *printer) text(n *html.Node) error {
 	}
 
 	// Otherwise, we additionally remove excess spaces.
-	s = collapseText(s, n)
+	s = p.collapseText(s, n)
+	if strings.HasPrefix(s, " ") && p.spaceBeforeStripped(n) {
+		s = s[1:]
+	}
 	if s == "" {
 		return nil
 	}
@@ -257,7 +603,7 @@ func (p *printer) text(n *html.Node) error {
 	// start with whitespace, since we don't want to reformat input like "(<a>link</a>)" as "(<a>link</a>\n)".
 	// We avoid "(\n<a>link</a>)" by being careful in how we wrap opening tags in openTag().
 	wrapStart := 0
-	if (inlineTags.has(n.PrevSibling) || inlineTags.has(n.Parent)) && !startSpace {
+	if (p.isInline(n.PrevSibling) || p.inlineContext(n.Parent)) && !startSpace {
 		wrapStart = 1
 	}
 
@@ -284,21 +630,90 @@ func (p *printer) text(n *html.Node) error {
 	return nil
 }
 
+// spaceBeforeStripped returns true if text node n follows one or more comments that
+// are removed by StripComments and are preceded by text ending in whitespace, so the
+// whitespace on both sides of the comments can be merged.
+func (p *printer) spaceBeforeStripped(n *html.Node) bool {
+	if !p.opts.StripComments {
+		return false
+	}
+	prev := n.PrevSibling
+	for ; prev != nil && prev.Type == html.CommentNode; prev = prev.PrevSibling {
+	}
+	if prev == n.PrevSibling || prev == nil || prev.Type != html.TextNode {
+		return false
+	}
+	return strings.TrimRight(prev.Data, "\t\n\f\r ") != prev.Data
+}
+
+// comment handles the supplied node of type html.CommentNode.
+func (p *printer) comment(n *html.Node) {
+	if n.Type != html.CommentNode {
+		panic(fmt.Sprintf("Got non-comment node %q (type %v)", n.Data, n.Type))
+	}
+	if p.opts.StripComments {
+		return
+	}
+
+	// Comments adjacent to inline content are printed inline so we don't add whitespace.
+	inline := p.isInline(n)
+	if !inline {
+		p.endl()
+	}
+	p.maybeIndent()
+
+	if !p.opts.WrapComments || p.inLiteral() || p.inKeepSpace() {
+		p.write("<!--" + n.Data + "-->")
+	} else {
+		// Write the comment one word at a time, keeping the closing "-->" with the last word.
+		start, end := "<!--", "-->"
+		if n.Data != "" && whitespace.MatchString(n.Data[:1]) {
+			start += " "
+		}
+		if n.Data != "" && whitespace.MatchString(n.Data[len(n.Data)-1:]) {
+			end = " " + end
+		}
+		words := strings.Fields(n.Data)
+		if len(words) == 0 {
+			p.write(start + strings.TrimLeft(end, " "))
+		}
+		for i, w := range words {
+			if i == 0 {
+				w = start + w
+			} else {
+				w = " " + w
+			}
+			if i == len(words)-1 {
+				w += end
+			}
+			if i == 0 {
+				p.write(w)
+			} else {
+				p.wrap(w, "")
+			}
+		}
+	}
+
+	if !inline {
+		p.endl()
+	}
+}
+
 // maybeIndent writes the proper amount of whitespace if we're at the start of a line
 // and not currently printing literally.
 func (p *printer) maybeIndent() {
 	if p.inLiteral() || p.inKeepSpace() || !p.lineStart {
 		return
 	}
-	s := strings.Repeat(p.indentStr, p.level)
+	s := strings.Repeat(p.opts.Indent, p.level)
 	p.write(s) // updates lineStart and lineWidth
 }
 
-// wrap writes s, first writing a newline and indentation if we would exceed p.wrapWidth.
+// wrap writes s, first writing a newline and indentation if we would exceed p.opts.Wrap.
 // extra denotes extra indentation to use if the line is wrapped.
 func (p *printer) wrap(s, extra string) {
 	if !p.inLiteral() && !p.inKeepSpace() &&
-		p.wrapWidth > 0 && p.lineWidth+len(s) > p.wrapWidth {
+		p.opts.Wrap > 0 && p.column(p.lineWidth, s) > p.opts.Wrap {
 		p.endl()
 		p.maybeIndent()
 		s = extra + strings.TrimLeft(s, " ")
@@ -320,14 +735,31 @@ func (p *printer) endl() {
 	p.lineWidth = 0
 }
 
-// write outputs s, sets lineStart to false, and increments lineWidth.
+// write outputs s, sets lineStart to false, and updates lineWidth.
+// Nothing is written after a plaintext element.
 func (p *printer) write(s string) {
-	if p.werr != nil {
+	if p.werr != nil || p.ended {
 		return
 	}
 	_, p.werr = io.WriteString(p.w, s)
 	p.lineStart = false
-	p.lineWidth += len(s)
+	p.lineWidth = p.column(p.lineWidth, s)
+}
+
+// column returns the column that would be reached by writing s starting at column col.
+// Tabs advance to the next multiple of p.opts.TabWidth, and newlines return to column 0.
+func (p *printer) column(col int, s string) int {
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			col = 0
+		case r == '\t' && p.opts.TabWidth > 0:
+			col = (col/p.opts.TabWidth + 1) * p.opts.TabWidth
+		default:
+			col += runeWidth(r)
+		}
+	}
+	return col
 }
 
 func (p *printer) openTag(n *html.Node) (forceInline bool) {
@@ -340,7 +772,7 @@ func (p *printer) openTag(n *html.Node) (forceInline bool) {
 			// Escape double-quotes.
 			// TODO: Ambiguous ampersands (/&[a-zA-Z0-9]+;/) are also disallowed, but I'm ignoring
 			// those for now. See https://html.spec.whatwg.org/multipage/syntax.html#syntax-attributes.
-			val := strings.Replace(a.Val, `"`, `&quot;`, -1)
+			val := strings.Replace(p.attrValue(a), `"`, `&quot;`, -1)
 
 			// Collapse repeated whitespace in 'class' attributes and remove leading and trailing
 			// spaces (https://html.spec.whatwg.org/multipage/dom.html#global-attributes:classes-2).
@@ -353,18 +785,18 @@ func (p *printer) openTag(n *html.Node) (forceInline bool) {
 		tokens = append(tokens, as)
 	}
 	tokens[len(tokens)-1] += ">" // avoid wrapping closing bracket since it'd look funny
-	tagLen := len(strings.Join(tokens, ""))
+	tagLen := stringWidth(strings.Join(tokens, ""))
 
 	// Start a new line for non-inline nodes. Also start inline nodes on a new line if they'd
 	// be wrapped... unless they're in or following another inline node or a text node that didn't end
 	// with whitespace or another inline node, in which case we need to be careful to not introduce
 	// new whitespace by wrapping.
-	inline := inlineTags.has(n)
-	wouldWrap := p.wrapWidth > 0 && p.lineWidth+tagLen > p.wrapWidth
+	inline := p.isInline(n)
+	wouldWrap := p.opts.Wrap > 0 && p.lineWidth+tagLen > p.opts.Wrap
 	prev := n.PrevSibling
 	prevTextNotSpace := prev != nil && prev.Type == html.TextNode &&
 		(prev.Data == "" || !whitespace.MatchString(prev.Data[len(prev.Data)-1:]))
-	startSpaceMatters := inlineTags.has(prev) || inlineTags.has(n.Parent) || prevTextNotSpace
+	startSpaceMatters := p.isInline(prev) || p.inlineContext(n.Parent) || prevTextNotSpace
 	if !inline || (wouldWrap && !startSpaceMatters) {
 		p.endl()
 	}
@@ -374,15 +806,15 @@ func (p *printer) openTag(n *html.Node) (forceInline bool) {
 
 	// If it looks like we can fit everything including the closing tag on a single line,
 	// treat this tag as inline.
-	if !literalTags.has(n) && !p.inLiteral() &&
-		!keepSpaceTags.has(n) && !p.inKeepSpace() {
+	if !p.literalTags.has(n) && !p.inLiteral() &&
+		!p.keepSpaceTags.has(n) && !p.inKeepSpace() {
 		childLen := -1
 		if n.FirstChild == nil {
 			childLen = 0
 		} else if hasSingleChild(n) && n.FirstChild.Type == html.TextNode {
-			childLen = len(collapseText(escapeText(n.FirstChild.Data), n.FirstChild))
+			childLen = stringWidth(p.collapseText(escapeText(n.FirstChild.Data), n.FirstChild))
 		}
-		if childLen >= 0 && (p.lineWidth+tagLen+childLen+len(closeTag(n)) < p.wrapWidth || p.wrapWidth <= 0) {
+		if childLen >= 0 && (p.lineWidth+tagLen+childLen+stringWidth(p.closeTag(n)) < p.opts.Wrap || p.opts.Wrap <= 0) {
 			forceInline = true
 		}
 	}
@@ -391,11 +823,11 @@ func (p *printer) openTag(n *html.Node) (forceInline bool) {
 	var wrapIndent string
 	if startedLine {
 		// Indent wrapped attributes two levels.
-		wrapIndent = strings.Repeat(p.indentStr, 2)
+		wrapIndent = strings.Repeat(p.opts.Indent, 2)
 		unwrapTokens = 1
 		// If the first token is shorter than the amount of indenting on the next
 		// line, it's better to put the second token on the first line.
-		if len(tokens[0]) < len(wrapIndent) {
+		if stringWidth(tokens[0]) < p.column(0, wrapIndent) {
 			unwrapTokens = 2
 		}
 	} else if (inline || forceInline) && startSpaceMatters {
@@ -412,6 +844,52 @@ func (p *printer) openTag(n *html.Node) (forceInline bool) {
 	return forceInline
 }
 
+// attrValue returns the value to print for a, formatting it first if needed.
+func (p *printer) attrValue(a html.Attribute) string {
+	if p.opts.FormatCSS && a.Namespace == "" && a.Key == "style" {
+		if v, err := formatStyleAttr(a.Val); err == nil {
+			return v
+		}
+	}
+	return a.Val
+}
+
+// isInline returns true if n is printed inline, i.e. without newlines around it.
+// Returns false if n is nil.
+func (p *printer) isInline(n *html.Node) bool {
+	if n == nil {
+		return false
+	}
+	switch n.Type {
+	case html.ElementNode:
+		switch p.display(n) {
+		case inlineDisplay, inlineBlockDisplay:
+			return true
+		case noneDisplay:
+			// Unrendered elements are treated like comments.
+			return p.adjacentInline(n)
+		}
+	case html.CommentNode:
+		return p.adjacentInline(n)
+	}
+	return false
+}
+
+// adjacentInline returns true if n is in an inline element or adjacent to inline content.
+// Comments and unrendered elements are printed inline in this case, since adding newlines
+// around them would introduce whitespace.
+func (p *printer) adjacentInline(n *html.Node) bool {
+	if p.isInline(n.Parent) {
+		return true
+	}
+	for _, s := range []*html.Node{n.PrevSibling, n.NextSibling} {
+		if p.inlineLevel(s) || (s != nil && s.Type == html.TextNode && strings.TrimSpace(s.Data) != "") {
+			return true
+		}
+	}
+	return false
+}
+
 // hasSingleChild returns true if n has a single child.
 func hasSingleChild(n *html.Node) bool {
 	return n.FirstChild != nil && n.FirstChild == n.LastChild
@@ -419,8 +897,9 @@ func hasSingleChild(n *html.Node) bool {
 
 // closeTag constructs a closing tag for n, e.g. "</strong>".
 // An empty string is returned if n is a void element or should omit its closing tag.
-func closeTag(n *html.Node) string {
-	if n.Type != html.ElementNode || voidTags.has(n) || omitCloseTags.has(n) {
+// plaintext never has a closing tag, since everything after its opening tag is text.
+func (p *printer) closeTag(n *html.Node) string {
+	if n.Type != html.ElementNode || p.voidTags.has(n) || p.omitClose(n) || n.Data == "plaintext" {
 		return ""
 	}
 	return "</" + n.Data + ">"
@@ -445,20 +924,21 @@ var whitespace *regexp.Regexp = regexp.MustCompile(`[\t\n\f\r ]+`)
 // same effect as the process described in "How does CSS process whitespace?" in
 // https://developer.mozilla.org/en-US/docs/Web/API/Document_Object_Model/Whitespace.
 //
-// This is probably woefully inadequate: HTML whitespace is very complicated and I don't
-// think it's actually possible to determine what's safe to do without knowing whether we're
-// an inline, block, or inline-block context, which seems like it'd require handling CSS.
-func collapseText(s string, n *html.Node) string {
+// This is probably woefully inadequate: HTML whitespace is very complicated and it's not
+// possible to determine what's safe to do without knowing whether we're in an inline, block,
+// or inline-block context. Options.SafeWhitespace approximates this using default CSS display
+// values, but stylesheets can still change them.
+func (p *printer) collapseText(s string, n *html.Node) string {
 	s = whitespace.ReplaceAllString(s, " ")
 
 	// Drop leading and trailing whitespace if we don't have siblings that will be printed
 	// adjacent to us -- we can presumably just use the printer's whitespace in that case.
 	// Preserve the whitespace if we're inside of an inline element, though.
-	if !inlineTags.has(n.Parent) {
-		if !inlineTags.has(n.PrevSibling) {
+	if !p.inlineContext(n.Parent) {
+		if !p.isInline(n.PrevSibling) {
 			s = strings.TrimLeft(s, " ")
 		}
-		if !inlineTags.has(n.NextSibling) {
+		if !p.isInline(n.NextSibling) {
 			s = strings.TrimRight(s, " ")
 		}
 	}
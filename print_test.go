@@ -5,6 +5,8 @@ package htmlpretty
 
 import (
 	"bytes"
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -12,17 +14,27 @@ import (
 )
 
 func checkPrint(t *testing.T, doc, indent string, wrap int, exp string) {
+	checkPrintOptions(t, doc, &Options{Indent: indent, Wrap: wrap}, exp)
+}
+
+func checkPrintOptions(t *testing.T, doc string, opts *Options, exp string) {
 	root, err := html.Parse(strings.NewReader(doc))
 	if err != nil {
 		t.Fatal("Parse failed: ", err)
 	}
 	var b bytes.Buffer
-	if err := Print(&b, root, indent, wrap); err != nil {
+	if err := PrintWithOptions(&b, root, opts); err != nil {
 		t.Fatal("Print failed: ", err)
 	}
-	if b.String() != exp {
+	checkOutput(t, b.String(), exp)
+}
+
+// checkOutput reports an error if out and exp differ.
+func checkOutput(t *testing.T, out, exp string) {
+	t.Helper()
+	if out != exp {
 		// Show the strings starting at the first non-matching line.
-		got := strings.Replace(b.String(), "\n", "|\n", -1)
+		got := strings.Replace(out, "\n", "|\n", -1)
 		want := strings.Replace(exp, "\n", "|\n", -1)
 		start := 0
 		for i := 0; i < len(got) && i < len(want) && got[i] == want[i]; i++ {
@@ -326,3 +338,382 @@ func TestPrint_PreserveSpaceBetweenInline(t *testing.T) {
 </html>
 `)
 }
+
+func TestPrintWithOptions_Nil(t *testing.T) {
+	checkPrintOptions(t, `<!DOCTYPE html>
+<html><head></head><body><p>Some text</p></body></html>`, nil, `<!DOCTYPE html>
+<html>
+<head></head>
+<body>
+<p>Some text</p>
+</body>
+</html>
+`)
+}
+
+func TestOptions_JSON(t *testing.T) {
+	opts := Options{Indent: "\t", Wrap: 100}
+	b, err := json.Marshal(&opts)
+	if err != nil {
+		t.Fatal("Marshal failed: ", err)
+	}
+	var got Options
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal("Unmarshal failed: ", err)
+	} else if !reflect.DeepEqual(got, opts) {
+		t.Errorf("Unmarshal(%s) = %+v; want %+v", b, got, opts)
+	}
+}
+
+func TestPrint_Comments(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html>
+  <head>
+    <!-- A comment in the head -->
+  </head>
+  <body>
+    <!--
+      Multi-line comment
+      that should be preserved
+    -->
+    <p>Text <!-- inline comment --> more text</p>
+    <p>Text<!--adjacent-->text</p>
+    <pre>  keep <!-- spaces -->  </pre>
+  </body>
+</html>`
+
+	checkPrintOptions(t, doc, &Options{Indent: "  ", Wrap: 80}, `<!DOCTYPE html>
+<html>
+  <head>
+    <!-- A comment in the head -->
+  </head>
+  <body>
+    <!--
+      Multi-line comment
+      that should be preserved
+    -->
+    <p>
+      Text <!-- inline comment --> more text
+    </p>
+    <p>
+      Text<!--adjacent-->text
+    </p>
+    <pre>  keep <!-- spaces -->  </pre>
+  </body>
+</html>
+`)
+
+	checkPrintOptions(t, doc, &Options{Indent: "  ", Wrap: 30, WrapComments: true}, `<!DOCTYPE html>
+<html>
+  <head>
+    <!-- A comment in the
+    head -->
+  </head>
+  <body>
+    <!-- Multi-line comment
+    that should be
+    preserved -->
+    <p>
+      Text <!-- inline
+      comment --> more text
+    </p>
+    <p>
+      Text<!--adjacent-->text
+    </p>
+    <pre>  keep <!-- spaces -->  </pre>
+  </body>
+</html>
+`)
+
+	checkPrintOptions(t, doc, &Options{Indent: "  ", Wrap: 80, StripComments: true}, `<!DOCTYPE html>
+<html>
+  <head>
+  </head>
+  <body>
+    <p>
+      Text more text
+    </p>
+    <p>
+      Texttext
+    </p>
+    <pre>  keep   </pre>
+  </body>
+</html>
+`)
+}
+
+func TestPrint_DocComments(t *testing.T) {
+	checkPrint(t, `<!-- License banner -->
+<!DOCTYPE html>
+<!--[if IE]><html class="ie"><![endif]-->
+<html><head></head><body>Body text</body></html>
+<!-- Trailing comment -->`, "  ", 80, `<!-- License banner -->
+<!DOCTYPE html>
+<!--[if IE]><html class="ie"><![endif]-->
+<html>
+  <head></head>
+  <body>Body text</body>
+</html>
+<!-- Trailing comment -->
+`)
+}
+
+func TestPrint_DocText(t *testing.T) {
+	// html.Parse never puts text directly under the document node, so build the tree manually.
+	root := &html.Node{Type: html.DocumentNode}
+	root.AppendChild(&html.Node{Type: html.TextNode, Data: "  Stray text\n"})
+	root.AppendChild(&html.Node{Type: html.ElementNode, Data: "p"})
+	var b bytes.Buffer
+	if err := Print(&b, root, "  ", 80); err != nil {
+		t.Fatal("Print failed: ", err)
+	}
+	checkOutput(t, b.String(), "Stray text\n<p></p>\n")
+}
+
+func checkPrintFragment(t *testing.T, frag, context string, opts *Options, exp string) {
+	t.Helper()
+	nodes, err := ParseFragment(strings.NewReader(frag), context)
+	if err != nil {
+		t.Fatal("ParseFragment failed: ", err)
+	}
+	var b bytes.Buffer
+	if err := PrintFragment(&b, nodes, opts); err != nil {
+		t.Fatal("PrintFragment failed: ", err)
+	}
+	checkOutput(t, b.String(), exp)
+}
+
+func TestPrintFragment(t *testing.T) {
+	checkPrintFragment(t, `
+<!-- Partial -->
+<div class="card"><h2>Title</h2>
+<p>Some <b>bold</b>   text</p></div>
+Trailing <a href="x.html">link</a>`, "", &Options{Indent: "  ", Wrap: 80}, `<!-- Partial -->
+<div class="card">
+  <h2>Title</h2>
+  <p>
+    Some <b>bold</b> text
+  </p>
+</div>
+Trailing <a href="x.html">link</a>
+`)
+
+	// Table rows are dropped unless the appropriate context is used.
+	checkPrintFragment(t, `<tr><td>A</td><td>B</td></tr>`, "tbody", &Options{Indent: "  "}, `<tr>
+  <td>A</td>
+  <td>B</td>
+</tr>
+`)
+}
+
+func TestPrintNode(t *testing.T) {
+	root, err := html.Parse(strings.NewReader(`<ul><li>One<li>Two</ul>`))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	body := root.FirstChild.LastChild
+	var b bytes.Buffer
+	if err := PrintNode(&b, body.FirstChild, &Options{Indent: "  "}); err != nil {
+		t.Fatal("PrintNode failed: ", err)
+	}
+	checkOutput(t, b.String(), "<ul>\n  <li>One\n  <li>Two\n</ul>\n")
+}
+
+func TestPrint_TagChanges(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html><head></head><body>
+<p>An icon: <my-icon>star</my-icon>, some text, and <span>a span</span>.</p>
+<dl><dt>Term</dt><dd>Definition</dd></dl>
+</body></html>`
+
+	checkPrintOptions(t, doc, &Options{Indent: "  ", Wrap: 80}, `<!DOCTYPE html>
+<html>
+  <head></head>
+  <body>
+    <p>
+      An icon:
+      <my-icon>star</my-icon>, some text, and <span>a span</span>.
+    </p>
+    <dl>
+      <dt>Term</dt>
+      <dd>Definition</dd>
+    </dl>
+  </body>
+</html>
+`)
+
+	checkPrintOptions(t, doc, &Options{
+		Indent: "  ",
+		Wrap:   80,
+		Tags: map[TagClass]TagChanges{
+			InlineClass:    {Add: []string{"MY-ICON", "dt", "dd"}, Remove: []string{"span"}},
+			ListClass:      {Add: []string{"dl"}},
+			OmitCloseClass: {Add: []string{"dd"}},
+		},
+	}, `<!DOCTYPE html>
+<html>
+  <head></head>
+  <body>
+    <p>
+      An icon: <my-icon>star</my-icon>, some text, and
+      <span>a span</span>.
+    </p>
+    <dl>
+      <dt>Term</dt>
+      <dd>Definition
+    </dl>
+  </body>
+</html>
+`)
+}
+
+func TestPrint_TagChangesInvalid(t *testing.T) {
+	root, err := html.Parse(strings.NewReader("<p>Text</p>"))
+	if err != nil {
+		t.Fatal("Parse failed: ", err)
+	}
+	for _, tags := range []map[TagClass]TagChanges{
+		{"bogus": {Add: []string{"p"}}},
+		{LiteralClass: {Add: []string{"br"}}},
+	} {
+		if err := PrintWithOptions(&bytes.Buffer{}, root, &Options{Tags: tags}); err == nil {
+			t.Errorf("PrintWithOptions with %v unexpectedly succeeded", tags)
+		}
+	}
+}
+
+func TestPrint_WideCharacters(t *testing.T) {
+	// Each of these words is 12 columns wide but 18 bytes long.
+	checkPrint(t, `<p>日本語の文章 東京都の地図 大阪府の地図 京都府の地図</p>`, "  ", 31, `<html>
+  <head></head>
+  <body>
+    <p>
+      日本語の文章 東京都の地図
+      大阪府の地図 京都府の地図
+    </p>
+  </body>
+</html>
+`)
+	// Fits on a single line when measured in columns.
+	checkPrint(t, `<p>Ünïcödé téxt</p>`, "  ", 24, `<html>
+  <head></head>
+  <body>
+    <p>Ünïcödé téxt</p>
+  </body>
+</html>
+`)
+}
+
+func TestPrint_TabWidth(t *testing.T) {
+	const doc = `<div><div><p>Some words that are wrapped based on the width of tabs</p></div></div>`
+	checkPrintOptions(t, doc, &Options{Indent: "\t", Wrap: 40}, `<html>
+	<head></head>
+	<body>
+		<div>
+			<div>
+				<p>
+					Some words that are wrapped based
+					on the width of tabs
+				</p>
+			</div>
+		</div>
+	</body>
+</html>
+`)
+	checkPrintOptions(t, doc, &Options{Indent: "\t", Wrap: 60, TabWidth: 8}, `<html>
+	<head></head>
+	<body>
+		<div>
+			<div>
+				<p>
+					Some words that are
+					wrapped based on the
+					width of tabs
+				</p>
+			</div>
+		</div>
+	</body>
+</html>
+`)
+}
+
+func TestPrint_SafeWhitespace(t *testing.T) {
+	const frag = `<form><label>Name</label><input name=n><button> Go </button>` +
+		`<select><option>A<option>B</select></form>` +
+		`<p>Text<script>f()</script>more</p><div hidden>a</div> <div>b</div>`
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  "}, `<form>
+  <label>Name</label>
+  <input name="n">
+  <button>Go</button>
+  <select>
+    <option>A</option>
+    <option>B</option>
+  </select>
+</form>
+<p>
+  Text
+  <script>f()</script>
+  more
+</p>
+<div hidden>a</div>
+<div>b</div>
+`)
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  ", SafeWhitespace: true}, `<form>
+  <label>Name</label><input name="n"><button>Go</button><select>
+    <option>A</option>
+    <option>B</option>
+  </select>
+</form>
+<p>
+  Text<script>f()</script>more
+</p>
+<div hidden>a</div>
+<div>b</div>
+`)
+	// Changes to the inline class take precedence over the default display values.
+	checkPrintFragment(t, `<p><label>a</label> <my-el>b</my-el></p>`, "body", &Options{
+		Indent:         "  ",
+		SafeWhitespace: true,
+		Tags:           map[TagClass]TagChanges{InlineClass: {Remove: []string{"my-el"}}},
+	}, `<p>
+  <label>a</label>
+  <my-el>b</my-el>
+</p>
+`)
+}
+
+func TestPrint_CSSDisplay(t *testing.T) {
+	const frag = `<style>nav a { display: block }</style>` +
+		`<nav><a href="1">One</a><a href="2">Two</a></nav>` +
+		`<div style="display: inline">a</div><div style="display: inline">b</div>`
+	checkPrintFragment(t, frag, "body", &Options{Indent: "  ", SafeWhitespace: true, CSSDisplay: true},
+		`<style>nav a { display: block }</style>
+<nav>
+  <a href="1">One</a>
+  <a href="2">Two</a>
+</nav>
+<div style="display: inline">a</div><div style="display: inline">b</div>
+`)
+}
+
+func TestPrint_TextElements(t *testing.T) {
+	checkPrint(t, "<title>  A  &amp;\n B  </title>"+
+		"<pre>\n\nfirst line blank</pre><pre>\nnot blank</pre>"+
+		"<textarea>\n  keep   this\n</textarea><listing>\n\nlisting</listing>"+
+		"<xmp>a &amp; <b></xmp><plaintext>rest <b> &amp;", "  ", 80, `<html>
+  <head>
+    <title>A &amp; B</title>
+  </head>
+  <body>
+    <pre>
+
+first line blank</pre>
+    <pre>not blank</pre>
+    <textarea>  keep   this
+</textarea>
+    <listing>
+
+listing</listing>
+    <xmp>a &amp; <b></xmp>
+    <plaintext>rest <b> &amp;`)
+}
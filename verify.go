@@ -0,0 +1,274 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// VerifyError is returned by Verify and VerifyFragment when the printed output is not
+// equivalent to the original tree.
+type VerifyError struct {
+	// Path identifies the first divergent node, e.g. "html/body/div[2]/p".
+	// Elements are numbered among siblings with the same tag name, starting at 1.
+	Path string
+	// Msg describes the difference.
+	Msg string
+}
+
+func (e *VerifyError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// Verify parses out, the pretty-printed form of the document rooted at root, and checks
+// that it's equivalent to root. The two trees must have the same elements and attributes,
+// and the same text after normalizing whitespace that isn't rendered according to the
+// user agent stylesheet (regardless of SafeWhitespace and InlineClass). Comments are ignored.
+// opts should be the options that were used to print out.
+// A *VerifyError is returned if the trees differ.
+func Verify(root *html.Node, out []byte, opts *Options) error {
+	p, err := newPrinter(nil, opts)
+	if err != nil {
+		return err
+	}
+	got, err := html.Parse(bytes.NewReader(out))
+	if err != nil {
+		return err
+	}
+	// Don't check the output using the same assumptions about whitespace that were used to print it.
+	p.displays = defaultDisplays
+	p.loadStyles([]*html.Node{root})
+	return p.verifyChildren("", root, got)
+}
+
+// VerifyFragment is like Verify, but for fragments parsed by ParseFragment using context
+// and printed by PrintFragment.
+func VerifyFragment(nodes []*html.Node, out []byte, context string, opts *Options) error {
+	p, err := newPrinter(nil, opts)
+	if err != nil {
+		return err
+	}
+	got, err := ParseFragment(bytes.NewReader(out), context)
+	if err != nil {
+		return err
+	}
+	// Don't check the output using the same assumptions about whitespace that were used to print it.
+	p.displays = defaultDisplays
+	p.loadStyles(nodes)
+	return p.verifyNodes("", nodes, got, false)
+}
+
+// verifyChildren compares the children of want and got, which are at path.
+func (p *printer) verifyChildren(path string, want, got *html.Node) error {
+	if p.formatter(want) != nil {
+		return nil // the contents may have been reformatted
+	}
+	var wantNodes, gotNodes []*html.Node
+	for c := want.FirstChild; c != nil; c = c.NextSibling {
+		wantNodes = append(wantNodes, c)
+	}
+	for c := got.FirstChild; c != nil; c = c.NextSibling {
+		gotNodes = append(gotNodes, c)
+	}
+	exact := p.literalTags.has(want) || p.keepSpaceTags.has(want)
+	return p.verifyNodes(path, wantNodes, gotNodes, exact)
+}
+
+// verifyNodes compares two lists of sibling nodes at path.
+// If exact is true, text is compared without normalizing whitespace.
+func (p *printer) verifyNodes(path string, want, got []*html.Node, exact bool) error {
+	var parent *html.Node
+	if len(want) > 0 {
+		parent = want[0].Parent
+	}
+	wantItems := p.verifyItems(want, parent, exact)
+	gotItems := p.verifyItems(got, parent, exact)
+
+	counts := make(map[string]int)
+	for i := 0; i < len(wantItems) || i < len(gotItems); i++ {
+		if i >= len(wantItems) {
+			return &VerifyError{path, "unexpected " + gotItems[i].String()}
+		}
+		if i >= len(gotItems) {
+			return &VerifyError{path, "missing " + wantItems[i].String()}
+		}
+		w, g := wantItems[i], gotItems[i]
+
+		if w.node == nil || g.node == nil {
+			if w.node != nil || g.node != nil || w.text != g.text {
+				return &VerifyError{joinPath(path, "text()"), fmt.Sprintf("got %v; want %v", g, w)}
+			}
+			continue
+		}
+
+		name := w.node.Data
+		if w.node.Type != html.ElementNode {
+			name = "node()"
+		}
+		counts[name]++
+		np := joinPath(path, name)
+		if n := countNamed(wantItems, name); n > 1 {
+			np += fmt.Sprintf("[%d]", counts[name])
+		}
+
+		if w.node.Type != g.node.Type || w.node.Data != g.node.Data || w.node.Namespace != g.node.Namespace {
+			return &VerifyError{np, fmt.Sprintf("got %v; want %v", g, w)}
+		}
+		if wa, ga := p.attrString(w.node), p.attrString(g.node); wa != ga {
+			return &VerifyError{np, fmt.Sprintf("got attributes %q; want %q", ga, wa)}
+		}
+		if err := p.verifyChildren(np, w.node, g.node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyItem is an element or normalized text run used by verifyNodes.
+type verifyItem struct {
+	node *html.Node // nil for text
+	text string
+}
+
+func (it verifyItem) String() string {
+	if it.node == nil {
+		return fmt.Sprintf("text %q", it.text)
+	}
+	switch it.node.Type {
+	case html.ElementNode:
+		return "<" + it.node.Data + ">"
+	case html.DoctypeNode:
+		return "doctype " + it.node.Data
+	default:
+		return fmt.Sprintf("node %q", it.node.Data)
+	}
+}
+
+// verifyItems converts nodes (children of parent) into items that can be compared.
+// Comments are dropped and adjacent text nodes are merged. Unless exact is true,
+// whitespace is collapsed and unrendered whitespace adjacent to block elements is dropped.
+func (p *printer) verifyItems(nodes []*html.Node, parent *html.Node, exact bool) []verifyItem {
+	var items []verifyItem
+	for _, n := range nodes {
+		switch n.Type {
+		case html.CommentNode:
+			continue
+		case html.TextNode:
+			if len(items) > 0 && items[len(items)-1].node == nil {
+				items[len(items)-1].text += n.Data
+			} else {
+				items = append(items, verifyItem{text: n.Data})
+			}
+		default:
+			items = append(items, verifyItem{node: n})
+		}
+	}
+	if exact {
+		return items
+	}
+
+	// The parent's edges are block boundaries unless it's inline.
+	// Unrendered elements are skipped when looking for the item next to a text run.
+	blockParent := !p.inlineContext(parent)
+	isBlock := func(n *html.Node) (block, skip bool) {
+		if n.Type != html.ElementNode {
+			return true, false
+		}
+		switch p.display(n) {
+		case blockDisplay, tableDisplay:
+			return true, false
+		case noneDisplay:
+			return false, true
+		}
+		return false, false
+	}
+
+	var res []verifyItem
+	for i, it := range items {
+		if it.node != nil {
+			res = append(res, it)
+			continue
+		}
+
+		// A leading space isn't rendered after a block boundary or another space.
+		it.text = whitespace.ReplaceAllString(it.text, " ")
+		trimStart := blockParent
+		for j := len(res) - 1; j >= 0; j-- {
+			if res[j].node == nil {
+				trimStart = strings.HasSuffix(res[j].text, " ")
+				break
+			}
+			if block, skip := isBlock(res[j].node); !skip {
+				trimStart = block
+				break
+			}
+		}
+		if trimStart {
+			it.text = strings.TrimLeft(it.text, " ")
+		}
+
+		// A trailing space isn't rendered before a block boundary.
+		trimEnd := blockParent
+		for j := i + 1; j < len(items); j++ {
+			if items[j].node == nil {
+				trimEnd = false
+				break
+			}
+			if block, skip := isBlock(items[j].node); !skip {
+				trimEnd = block
+				break
+			}
+		}
+		if trimEnd {
+			it.text = strings.TrimRight(it.text, " ")
+		}
+
+		if it.text != "" {
+			res = append(res, it)
+		}
+	}
+	return res
+}
+
+// countNamed returns the number of elements in items with the supplied tag name.
+func countNamed(items []verifyItem, name string) int {
+	var n int
+	for _, it := range items {
+		if it.node != nil && it.node.Type == html.ElementNode && it.node.Data == name {
+			n++
+		}
+	}
+	return n
+}
+
+// joinPath appends name to path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+// attrString returns a normalized string describing n's attributes.
+func (p *printer) attrString(n *html.Node) string {
+	var attrs []string
+	for _, a := range n.Attr {
+		val := p.attrValue(a)
+		if a.Key == "class" {
+			val = strings.TrimSpace(whitespace.ReplaceAllString(val, " "))
+		}
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + key
+		}
+		attrs = append(attrs, fmt.Sprintf("%s=%q", key, val))
+	}
+	sort.Strings(attrs)
+	return strings.Join(attrs, " ")
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestVerify(t *testing.T) {
+	for _, tc := range []struct {
+		in, out string
+		path    string // expected VerifyError path, or empty if no error
+	}{
+		{"<p>Hello <b>there</b></p>", "<p>\n  Hello <b>there</b>\n</p>\n", ""},
+		{"<div>  <p>a  b</p> <p>c</p></div>", "<div><p>a b</p><p>c</p></div>", ""},
+		{"<p>a<!-- c -->  b</p>", "<p>a b</p>", ""},
+		{`<p class="a  b" id="x">a</p>`, `<p id="x" class="a b">a</p>`, ""},
+		{"<pre>a  b</pre>", "<pre>a  b</pre>", ""},
+		{"<pre>a  b</pre>", "<pre>a b</pre>", "html/body/pre/text()"},
+		{"<p>a<b>b</b></p>", "<p>a <b>b</b></p>", "html/body/p/text()"},
+		{"<p>a</p><p>b</p>", "<p>a</p><div>b</div>", "html/body/p[2]"},
+		{"<p>a</p><p id=x>b</p>", "<p>a</p><p>b</p>", "html/body/p[2]"},
+		{"<div><span>a</span></div>", "<div></div>", "html/body/div"},
+		{"<script>a  b</script>", "<script>a b</script>", "html/head/script/text()"},
+	} {
+		root, err := html.Parse(strings.NewReader(tc.in))
+		if err != nil {
+			t.Fatal("Parse failed:", err)
+		}
+		err = Verify(root, []byte(tc.out), nil)
+		if tc.path == "" {
+			if err != nil {
+				t.Errorf("Verify(%q, %q) failed: %v", tc.in, tc.out, err)
+			}
+		} else if verr, ok := err.(*VerifyError); !ok {
+			t.Errorf("Verify(%q, %q) returned %v; want VerifyError", tc.in, tc.out, err)
+		} else if verr.Path != tc.path {
+			t.Errorf("Verify(%q, %q) returned path %q; want %q", tc.in, tc.out, verr.Path, tc.path)
+		}
+	}
+}
+
+func TestVerify_Print(t *testing.T) {
+	const doc = `<!DOCTYPE html>
+<html><head><title>Test</title><style>p { color: red; }</style></head>
+<body><!-- comment --><div class="a"><p>Some <b>bold</b> and <i>italic</i>
+text   that is long enough to wrap.</p><ul><li>One<li>Two</ul><pre>  keep
+  this</pre></div></body></html>`
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("Parse failed:", err)
+	}
+	for _, opts := range []*Options{
+		{Indent: "  ", Wrap: 20},
+		{Indent: "\t", Wrap: 0, StripComments: true},
+	} {
+		var b bytes.Buffer
+		if err := PrintWithOptions(&b, root, opts); err != nil {
+			t.Fatal("PrintWithOptions failed:", err)
+		}
+		if err := Verify(root, b.Bytes(), opts); err != nil {
+			t.Errorf("Verify with %+v failed: %v\n%s", *opts, err, b.String())
+		}
+	}
+}
+
+func TestVerifyFragment(t *testing.T) {
+	const frag = "<td>a <b>b</b></td><td>c</td>"
+	nodes, err := ParseFragment(strings.NewReader(frag), "tr")
+	if err != nil {
+		t.Fatal("ParseFragment failed:", err)
+	}
+	if err := VerifyFragment(nodes, []byte("<td>\n  a <b>b</b>\n</td>\n<td>c</td>\n"), "tr", nil); err != nil {
+		t.Error("VerifyFragment failed:", err)
+	}
+	err = VerifyFragment(nodes, []byte("<td>a <b>b</b></td>"), "tr", nil)
+	if verr, ok := err.(*VerifyError); !ok || verr.Path != "" {
+		t.Errorf("VerifyFragment with missing cell returned %v; want VerifyError with empty path", err)
+	}
+}
+
+func TestVerify_SafeWhitespace(t *testing.T) {
+	const doc = "<p><label>a</label><input></p>"
+	const out = "<p>\n  <label>a</label>\n  <input>\n</p>\n"
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal("Parse failed:", err)
+	}
+	// The inserted whitespace is rendered between the inline label and inline-block input,
+	// even if the output was printed without SafeWhitespace.
+	for _, opts := range []*Options{nil, {SafeWhitespace: true}} {
+		err = Verify(root, []byte(out), opts)
+		if verr, ok := err.(*VerifyError); !ok || verr.Path != "html/body/p/text()" {
+			t.Errorf("Verify with %+v returned %v; want VerifyError for html/body/p/text()", opts, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"sort"
+	"unicode"
+)
+
+// stringWidth returns the number of columns needed to display s in a monospace font.
+func stringWidth(s string) int {
+	var w int
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+// runeWidth returns the number of columns needed to display r in a monospace font.
+// East Asian Wide and Fullwidth characters occupy two columns, while combining marks,
+// format characters, emoji modifiers, and control characters (other than tab) occupy
+// zero columns.
+func runeWidth(r rune) int {
+	switch {
+	case r == '\t':
+		return 1
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x300: // fast path for Latin text
+		if r == 0xad { // soft hyphen
+			return 0
+		}
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || (r >= 0x1160 && r <= 0x11ff):
+		// Hangul Jamo medial vowels and final consonants combine with the preceding character.
+		return 0
+	case r >= 0x1f3fb && r <= 0x1f3ff:
+		// Skin tone modifiers are rendered as part of the preceding emoji.
+		return 0
+	case isWide(r):
+		return 2
+	}
+	return 1
+}
+
+// wideRanges contains inclusive ranges of East Asian Wide (W) and Fullwidth (F) characters,
+// as listed in https://www.unicode.org/Public/UCD/latest/ucd/EastAsianWidth.txt.
+// Some small ranges are merged to keep the table short.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115f},   // Hangul Jamo initial consonants
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x2329, 0x232a},   // angle brackets
+	{0x23e9, 0x23ec},   // media controls
+	{0x23f0, 0x23f0},   // alarm clock
+	{0x23f3, 0x23f3},   // hourglass with flowing sand
+	{0x25fd, 0x25fe},   // small squares
+	{0x2614, 0x2615},   // umbrella, hot beverage
+	{0x2648, 0x2653},   // zodiac signs
+	{0x267f, 0x267f},   // wheelchair symbol
+	{0x2693, 0x2693},   // anchor
+	{0x26a1, 0x26a1},   // high voltage
+	{0x26aa, 0x26ab},   // circles
+	{0x26bd, 0x26be},   // soccer ball, baseball
+	{0x26c4, 0x26c5},   // snowman, sun behind cloud
+	{0x26ce, 0x26ce},   // ophiuchus
+	{0x26d4, 0x26d4},   // no entry
+	{0x26ea, 0x26ea},   // church
+	{0x26f2, 0x26f3},   // fountain, flag in hole
+	{0x26f5, 0x26f5},   // sailboat
+	{0x26fa, 0x26fa},   // tent
+	{0x26fd, 0x26fd},   // fuel pump
+	{0x2705, 0x2705},   // check mark button
+	{0x270a, 0x270b},   // raised fist and hand
+	{0x2728, 0x2728},   // sparkles
+	{0x274c, 0x274c},   // cross mark
+	{0x274e, 0x274e},   // cross mark button
+	{0x2753, 0x2755},   // question and exclamation marks
+	{0x2757, 0x2757},   // exclamation mark
+	{0x2795, 0x2797},   // plus, minus, divide
+	{0x27b0, 0x27b0},   // curly loop
+	{0x27bf, 0x27bf},   // double curly loop
+	{0x2b1b, 0x2b1c},   // large squares
+	{0x2b50, 0x2b50},   // star
+	{0x2b55, 0x2b55},   // heavy large circle
+	{0x2e80, 0x303e},   // CJK radicals, Kangxi radicals, CJK symbols and punctuation
+	{0x3041, 0x33ff},   // Hiragana, Katakana, Bopomofo, Hangul compatibility Jamo, etc.
+	{0x3400, 0x4dbf},   // CJK unified ideographs extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xa960, 0xa97f},   // Hangul Jamo extended A
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe10, 0xfe19},   // vertical forms
+	{0xfe30, 0xfe6f},   // CJK compatibility forms, small form variants
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x16fe0, 0x16fe4}, // ideographic symbols and punctuation
+	{0x17000, 0x18cff}, // Tangut, Khitan
+	{0x1b000, 0x1b2ff}, // Kana supplement and extensions, Nushu
+	{0x1f004, 0x1f004}, // mahjong tile red dragon
+	{0x1f0cf, 0x1f0cf}, // playing card black joker
+	{0x1f18e, 0x1f18e}, // AB button
+	{0x1f191, 0x1f19a}, // squared words
+	{0x1f200, 0x1f265}, // enclosed ideographic supplement
+	{0x1f300, 0x1f64f}, // miscellaneous symbols and pictographs, emoticons
+	{0x1f680, 0x1f6ff}, // transport and map symbols
+	{0x1f7e0, 0x1f7eb}, // colored circles and squares
+	{0x1f90c, 0x1f9ff}, // supplemental symbols and pictographs
+	{0x1fa70, 0x1faff}, // symbols and pictographs extended A
+	{0x20000, 0x2fffd}, // CJK unified ideographs extensions B through F
+	{0x30000, 0x3fffd}, // CJK unified ideographs extension G and beyond
+}
+
+// isWide returns true if r is an East Asian Wide or Fullwidth character.
+func isWide(r rune) bool {
+	i := sort.Search(len(wideRanges), func(i int) bool { return wideRanges[i][1] >= r })
+	return i < len(wideRanges) && r >= wideRanges[i][0]
+}
@@ -0,0 +1,33 @@
+// Copyright 2020 Daniel Erat <dan@erat.org>.
+// All rights reserved.
+
+package htmlpretty
+
+import (
+	"testing"
+)
+
+func TestStringWidth(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"café", 4},
+		{"cafe\u0301", 4}, // combining acute accent
+		{"日本語", 6},
+		{"ｈｅｌｌｏ", 10}, // fullwidth Latin letters
+		{"ｶﾀｶﾅ", 4},   // halfwidth Katakana
+		{"한국어", 6},
+		{"👍", 2},
+		{"👍🏽", 2},       // skin tone modifiers combine with the preceding emoji
+		{"a\u200bb", 2}, // zero-width space
+		{"\u00ad", 0},   // soft hyphen
+		{"a\tb", 3},
+	} {
+		if got := stringWidth(tc.s); got != tc.want {
+			t.Errorf("stringWidth(%q) = %d; want %d", tc.s, got, tc.want)
+		}
+	}
+}